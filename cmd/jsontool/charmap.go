@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/charmap"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/project"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+//noinspection GoStructTag
+type CharmapCommand struct {
+	File string `arg name:"file" help:"Path to the binary file to inspect."`
+	Json bool   `help:"Print JSON instead of a table."`
+}
+
+type CharmapRuneJson struct {
+	Char  string
+	Code  string
+	Index int
+	Bytes string
+}
+
+type CharmapJson struct {
+	NumEntries int
+	Free       int
+	Jumps      []charmap.JumpEntry
+	SingleByte []CharmapRuneJson
+	TwoByte    []CharmapRuneJson
+}
+
+func FormatBytes(b []byte) string {
+	if b == nil {
+		return "-"
+	}
+	parts := make([]string, len(b))
+	for i, c := range b {
+		parts[i] = fmt.Sprintf("%02X", c)
+	}
+	return strings.Join(parts, " ")
+}
+
+func charmapRunesJson(entries []charmap.RuneEntry) []CharmapRuneJson {
+	out := make([]CharmapRuneJson, len(entries))
+	for i, e := range entries {
+		out[i] = CharmapRuneJson{
+			Char:  string(e.Rune),
+			Code:  fmt.Sprintf("%U", e.Rune),
+			Index: e.Index,
+			Bytes: FormatBytes(e.Bytes),
+		}
+	}
+	return out
+}
+
+func (r *CharmapCommand) Run(_ *Context) error {
+	lf, err := project.LoadLangFile(r.File)
+
+	if err != nil {
+		return err
+	}
+
+	layout := lf.CharMap.Inspect()
+
+	if r.Json {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", " ")
+		return encoder.Encode(&CharmapJson{
+			NumEntries: layout.NumEntries,
+			Free:       layout.Free,
+			Jumps:      layout.Jumps,
+			SingleByte: charmapRunesJson(layout.SingleByte),
+			TwoByte:    charmapRunesJson(layout.TwoByte),
+		})
+	}
+
+	fmt.Printf("NumEntries: %d (0x%X)\n", layout.NumEntries, layout.NumEntries)
+	fmt.Printf("Single byte runes: %d\n", len(layout.SingleByte))
+	fmt.Printf("Two byte runes: %d\n", len(layout.TwoByte))
+	fmt.Printf("Free entries: %d of %d\n", layout.Free, charmap.TableSize)
+
+	fmt.Println()
+	fmt.Println("Jump entries:")
+	for _, j := range layout.Jumps {
+		fmt.Printf("  0x%02X -> page %d (entries 0x%03X-0x%03X)\n", j.Index, j.Page, j.Page*128, j.Page*128+127)
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Index\tChar\tCode\tBytes")
+	for _, entries := range [][]charmap.RuneEntry{layout.SingleByte, layout.TwoByte} {
+		for _, e := range entries {
+			fmt.Fprintf(w, "0x%03X\t%c\t%U\t%s\n", e.Index, e.Rune, e.Rune, FormatBytes(e.Bytes))
+		}
+	}
+
+	return w.Flush()
+}
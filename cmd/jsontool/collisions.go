@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/format"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/hashdict"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/project"
+)
+
+//noinspection GoStructTag
+type CollisionsCommand struct {
+	DataPath string   `arg name:"in" help:"Path to folder with text files"`
+	Dict     []string `help:"Label list files to check along with the project labels." type:"existingfile"`
+	Format   string   `help:"Text format to read." default:"json"`
+}
+
+func (r *CollisionsCommand) Run(_ *Context) error {
+	codec, err := TextCodec(r.Format)
+
+	if err != nil {
+		return err
+	}
+
+	packs, err := format.FindProjects(r.DataPath, codec)
+
+	if err != nil {
+		return err
+	}
+
+	extra := make([]string, 0)
+	for _, fp := range r.Dict {
+		labels, err := hashdict.LoadLabels(fp)
+
+		if err != nil {
+			return err
+		}
+
+		extra = append(extra, labels...)
+	}
+
+	total := 0
+	for _, pack := range packs {
+		p, err := project.LoadText(r.DataPath, pack, codec)
+
+		if err != nil {
+			return err
+		}
+
+		labels := append(append([]string(nil), extra...), p.Labels.Labels()...)
+		for _, lang := range p.LanguageNames() {
+			labels = append(labels, p.Languages[lang].Labels()...)
+		}
+
+		for _, c := range hashdict.Collisions(labels) {
+			fmt.Printf("%s: %v\n", pack, c)
+			total++
+		}
+	}
+
+	if total > 0 {
+		return fmt.Errorf("found %d hash collisions", total)
+	}
+
+	fmt.Println("No hash collisions")
+	return nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/WorldUnitedNFS/worldlangedit/lib"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/format"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/hashdict"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/project"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// crackSaveInterval is how often a crack run saves its progress.
+const crackSaveInterval = 30 * time.Second
+
+//noinspection GoStructTag
+type CrackCommand struct {
+	DataPath  string   `arg name:"in" help:"Path to folder with text files"`
+	Pack      string   `help:"Pack to crack the unlabeled hashes of" default:"Global"`
+	Hash      []string `help:"Hashes to crack instead of the unlabeled ones in the pack."`
+	Dict      []string `help:"Label list files to take tokens from in addition to the Labels file." type:"existingfile"`
+	MaxTokens int      `help:"Maximum number of tokens in a candidate label." default:"3"`
+	Workers   int      `help:"Number of parallel workers, defaults to the number of CPUs."`
+	State     string   `help:"File to save progress to and resume from."`
+	Format    string   `help:"Text format to read." default:"json"`
+}
+
+// CrackState is the progress of a crack run. Tokens identifies the token list
+// so a state file is not resumed with a different vocabulary.
+type CrackState struct {
+	Tokens    uint32
+	MaxTokens int
+	Done      []hashdict.Unit
+	Found     hashdict.Candidates
+}
+
+func loadCrackState(fp string) (*CrackState, error) {
+	data, err := ioutil.ReadFile(fp)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	state := &CrackState{}
+	err = json.Unmarshal(data, state)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+func saveCrackState(fp string, state *CrackState) error {
+	data, err := json.Marshal(state)
+
+	if err != nil {
+		return err
+	}
+
+	tmp := fp + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, fp)
+}
+
+// crackTargets returns the hashes to crack and the labels to take tokens from.
+func (r *CrackCommand) crackTargets() ([]uint32, []string, error) {
+	dict := hashdict.New()
+	targets := make(map[uint32]bool)
+
+	for _, h := range r.Hash {
+		hash, ok := hashdict.ParseHexKey(h)
+		if !ok {
+			return nil, nil, fmt.Errorf("%s is not a hash, expected 0x followed by 8 hex digits", h)
+		}
+		targets[hash] = true
+	}
+
+	codec, err := TextCodec(r.Format)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p, err := project.LoadText(r.DataPath, r.Pack, codec)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	packs := []*format.Pack{p.Labels}
+	for _, lang := range p.LanguageNames() {
+		packs = append(packs, p.Languages[lang])
+	}
+
+	// packs[0] is the Labels pack, the only one whose labels are tokens
+	for i, lp := range packs {
+		for l := range lp.Entries {
+			hash, ok := hashdict.ParseHexKey(l)
+			switch {
+			case !ok && i == 0:
+				dict.Add(l)
+			case ok && len(r.Hash) == 0:
+				targets[hash] = true
+			}
+		}
+	}
+
+	for _, fp := range r.Dict {
+		if err := dict.LoadFile(fp); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	hashes := make([]uint32, 0, len(targets))
+	for h := range targets {
+		hashes = append(hashes, h)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+
+	return hashes, dict.Labels(), nil
+}
+
+func (r *CrackCommand) Run(_ *Context) error {
+	targets, labels, err := r.crackTargets()
+
+	if err != nil {
+		return err
+	}
+
+	if len(targets) == 0 {
+		fmt.Println("No hashes to crack")
+		return nil
+	}
+
+	tokens := hashdict.Tokens(labels)
+	state := &CrackState{
+		Tokens:    lib.BinHash(strings.Join(tokens, "\n")),
+		MaxTokens: r.MaxTokens,
+		Done:      make([]hashdict.Unit, 0),
+		Found:     make(hashdict.Candidates),
+	}
+
+	if r.State != "" {
+		saved, err := loadCrackState(r.State)
+
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %v", r.State, err)
+		}
+
+		if saved != nil {
+			if saved.Tokens != state.Tokens || saved.MaxTokens != state.MaxTokens {
+				return fmt.Errorf("%s was saved with a different vocabulary or token limit", r.State)
+			}
+			state = saved
+			fmt.Printf("Resuming from %s: %d units done, %d hashes found\n", r.State, len(state.Done), len(state.Found))
+		}
+	}
+
+	workers := r.Workers
+	if workers == 0 {
+		workers = runtime.NumCPU()
+	}
+
+	c := hashdict.NewCracker(tokens, targets, r.MaxTokens)
+	done := make(map[hashdict.Unit]bool, len(state.Done))
+	for _, u := range state.Done {
+		done[u] = true
+	}
+	units := make([]hashdict.Unit, 0)
+	for _, u := range c.Units() {
+		if !done[u] {
+			units = append(units, u)
+		}
+	}
+
+	fmt.Printf("Cracking %d hashes with %d tokens, %d units left on %d workers\n", len(targets), len(tokens), len(units), workers)
+
+	stop := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	finished := make(chan struct{})
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			fmt.Println("Interrupted, finishing the units in progress")
+			close(stop)
+		case <-finished:
+		}
+	}()
+
+	var saveErr error
+	lastSave := time.Now()
+	c.Run(units, workers, stop, func(hash uint32, label string) {
+		if state.Found.Add(hash, label) {
+			fmt.Printf("%s %s\n", hashdict.HexKey(hash), label)
+		}
+	}, func(u hashdict.Unit) {
+		state.Done = append(state.Done, u)
+		if r.State != "" && saveErr == nil && time.Since(lastSave) >= crackSaveInterval {
+			saveErr = saveCrackState(r.State, state)
+			lastSave = time.Now()
+		}
+	})
+	close(finished)
+
+	if r.State != "" && saveErr == nil {
+		saveErr = saveCrackState(r.State, state)
+	}
+
+	if saveErr != nil {
+		return fmt.Errorf("failed to save %s: %v", r.State, saveErr)
+	}
+
+	select {
+	case <-stop:
+		fmt.Printf("Stopped with %d of %d units done\n", len(state.Done), len(c.Units()))
+	default:
+	}
+
+	fmt.Printf("Found candidates for %d of %d hashes\n", len(state.Found), len(targets))
+	return nil
+}
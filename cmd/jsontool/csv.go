@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/project"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//noinspection GoStructTag
+type ExportCsvCommand struct {
+	DataPath string `arg name:"in" help:"Path to folder with text files"`
+	Output   string `arg name:"out" help:"CSV file to write, a .tsv extension writes tab separated values"`
+	Pack     string `help:"Pack to export" default:"Global"`
+	Format   string `help:"Text format to read." default:"json"`
+}
+
+//noinspection GoStructTag
+type ImportCsvCommand struct {
+	DataPath string `arg name:"in" help:"Path to folder with text files"`
+	File     string `arg name:"file" help:"CSV or TSV file to import. Empty cells are left alone." type:"existingfile"`
+	Pack     string `help:"Pack to import into" default:"Global"`
+	Format   string `help:"Text format to read and write." default:"json"`
+}
+
+// csvComma returns the separator used for a spreadsheet file.
+func csvComma(fp string) rune {
+	if strings.EqualFold(filepath.Ext(fp), ".tsv") {
+		return '\t'
+	}
+	return ','
+}
+
+func (r *ExportCsvCommand) Run(_ *Context) error {
+	codec, err := TextCodec(r.Format)
+
+	if err != nil {
+		return err
+	}
+
+	p, err := project.LoadText(r.DataPath, r.Pack, codec)
+
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(r.Output)
+
+	if err != nil {
+		return err
+	}
+
+	err = p.WriteCsv(f, csvComma(r.Output))
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %d labels in %d languages to %s\n", len(p.Labels.Entries), len(p.EditableLanguages()), r.Output)
+	return nil
+}
+
+func (r *ImportCsvCommand) Run(_ *Context) error {
+	codec, err := TextCodec(r.Format)
+
+	if err != nil {
+		return err
+	}
+
+	p, err := project.LoadText(r.DataPath, r.Pack, codec)
+
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(r.File)
+
+	if err != nil {
+		return err
+	}
+
+	changes, err := p.ApplyCsv(f, csvComma(r.File))
+	_ = f.Close()
+
+	if err != nil {
+		return fmt.Errorf("failed to import %s: %v", r.File, err)
+	}
+
+	changed := make([]string, 0, len(changes))
+	for _, lang := range p.EditableLanguages() {
+		n, imported := changes[lang]
+
+		if !imported {
+			continue
+		}
+
+		fmt.Printf("%s: %d strings changed\n", lang, n)
+
+		if n > 0 {
+			changed = append(changed, lang)
+		}
+	}
+
+	if len(changed) > 0 {
+		return p.SaveLanguages(r.DataPath, codec, changed...)
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/charmap"
+	"os"
+	"unicode/utf8"
+)
+
+func FallbackPolicy(name string) charmap.FallbackPolicy {
+	switch name {
+	case "replace":
+		return charmap.FallbackReplace
+	case "transliterate":
+		return charmap.FallbackTransliterate
+	default:
+		return charmap.FallbackError
+	}
+}
+
+// LoadTransliterations reads a transliteration table from a JSON object
+// mapping single characters to their replacement.
+func LoadTransliterations(fp string) (map[rune]string, error) {
+	f, err := os.Open(fp)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	raw := make(map[string]string)
+	if err := json.NewDecoder(f).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	table := make(map[rune]string, len(raw))
+	for k, v := range raw {
+		c, size := utf8.DecodeRuneInString(k)
+		if size != len(k) || c == utf8.RuneError {
+			return nil, fmt.Errorf("transliteration key %q is not a single character", k)
+		}
+		table[c] = v
+	}
+
+	return table, nil
+}
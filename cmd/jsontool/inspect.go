@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/WorldUnitedNFS/worldlangedit/lib"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/chunk"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+)
+
+//noinspection GoStructTag
+type InspectCommand struct {
+	File string `arg name:"file" help:"Path to the binary file to inspect."`
+	Json bool   `help:"Print JSON instead of text."`
+}
+
+type InspectChunkJson struct {
+	Offset string
+	ID     string
+	Length int
+	Type   string
+}
+
+type InspectEntryJson struct {
+	Hash   string
+	Offset string
+	Bytes  string
+	String string
+	Error  string `json:",omitempty"`
+}
+
+type InspectJson struct {
+	Encoding      string
+	Size          int
+	Chunks        []InspectChunkJson
+	EntryCount    uint32
+	TableOffset   string
+	StringsOffset string
+	Name          string
+	CharmapSize   int
+	NumEntries    int32
+	Table         []InspectEntryJson
+	Errors        []string
+}
+
+func ChunkType(id uint32) string {
+	switch id {
+	case chunk.PaddingID:
+		return "padding"
+	case lib.StringChunkID:
+		return "strings"
+	case lib.CharMapChunkID:
+		return "charmap"
+	default:
+		return "unknown"
+	}
+}
+
+func (r *InspectCommand) Run(_ *Context) error {
+	data, err := ioutil.ReadFile(r.File)
+
+	if err != nil {
+		return err
+	}
+
+	info, err := lib.Inspect(data)
+
+	if err != nil {
+		return err
+	}
+
+	out := &InspectJson{
+		Encoding:      info.Encoding.String(),
+		Size:          info.Size,
+		Chunks:        make([]InspectChunkJson, len(info.Chunks)),
+		EntryCount:    info.Strings.Count,
+		TableOffset:   fmt.Sprintf("0x%X", info.Strings.TableOffset),
+		StringsOffset: fmt.Sprintf("0x%X", info.Strings.StringsOffset),
+		Name:          info.Strings.Name,
+		Table:         make([]InspectEntryJson, len(info.Table)),
+		Errors:        make([]string, len(info.Errors)),
+	}
+
+	if info.CharMap != nil {
+		out.NumEntries = info.CharMap.NumEntries
+	}
+
+	for i, err := range info.Errors {
+		out.Errors[i] = err.Error()
+	}
+
+	for i, c := range info.Chunks {
+		out.Chunks[i] = InspectChunkJson{
+			Offset: fmt.Sprintf("0x%X", c.Offset),
+			ID:     fmt.Sprintf("0x%X", c.ID),
+			Length: c.Length,
+			Type:   ChunkType(c.ID),
+		}
+		if c.ID == lib.CharMapChunkID && out.CharmapSize == 0 {
+			out.CharmapSize = c.Length
+		}
+	}
+
+	for i, e := range info.Table {
+		out.Table[i] = InspectEntryJson{
+			Hash:   lib.HexKey(e.Hash),
+			Offset: fmt.Sprintf("0x%X", e.Offset),
+			Bytes:  FormatBytes(e.Raw),
+			String: e.String,
+		}
+		if e.Err != nil {
+			out.Table[i].Error = e.Err.Error()
+		}
+	}
+
+	if r.Json {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", " ")
+		return encoder.Encode(out)
+	}
+
+	fmt.Printf("File: %s (%d bytes, %s)\n", r.File, out.Size, out.Encoding)
+
+	if len(out.Errors) > 0 {
+		fmt.Println()
+		fmt.Println("Errors:")
+		for _, err := range out.Errors {
+			fmt.Println(" ", err)
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("Chunks:")
+	for _, c := range out.Chunks {
+		fmt.Printf("  %-8s id %-8s length 0x%X (%s)\n", c.Offset, c.ID, c.Length, c.Type)
+	}
+
+	fmt.Println()
+	fmt.Println("String chunk:")
+	fmt.Printf("  Entry count: %d\n", out.EntryCount)
+	fmt.Printf("  Table offset: %s\n", out.TableOffset)
+	fmt.Printf("  Strings offset: %s\n", out.StringsOffset)
+	fmt.Printf("  Name: %q\n", out.Name)
+
+	fmt.Println()
+	fmt.Println("Charmap chunk:")
+	fmt.Printf("  Length: 0x%X\n", out.CharmapSize)
+	fmt.Printf("  NumEntries: %d (0x%X)\n", out.NumEntries, out.NumEntries)
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Hash\tOffset\tBytes\tString\tError")
+	for _, e := range out.Table {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%q\t%s\n", e.Hash, e.Offset, e.Bytes, e.String, e.Error)
+	}
+
+	return w.Flush()
+}
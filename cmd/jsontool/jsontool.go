@@ -1,86 +1,27 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/WorldUnitedNFS/worldlangedit/lib"
 	"github.com/WorldUnitedNFS/worldlangedit/lib/charmap"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/format"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/hashdict"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/project"
 	"github.com/alecthomas/kong"
-	"io/ioutil"
 	"os"
-	"path"
-	"path/filepath"
 	"strings"
 )
 
-func FilenameWithoutExtension(fn string) string {
-	return strings.TrimSuffix(fn, path.Ext(fn))
-}
-
-func BuildCharMap(chars []rune) *charmap.Charmap {
-	newCharMap := &charmap.Charmap{
-		NumEntries: 0,
-		EntryTable: [3072]uint16{},
-	}
-
-	numEntries := int32(0x80) // 0x00-0x7F get reserved spaces
-	numEntries += int32(len(chars))
-
-	tmpNumEntries := numEntries
-
-	jumpEntries := make([]uint16, 0)
-	maxJumpEntry := tmpNumEntries >> 7
-
-	if maxJumpEntry >= 2 {
-		jumpEntries = append(jumpEntries, uint16(maxJumpEntry))
-		tmpNumEntries++
-	}
-
-	// Determine jump entries
-	for {
-		newMaxJumpEntry := tmpNumEntries >> 7
-
-		if newMaxJumpEntry > maxJumpEntry {
-			tmpNumEntries++
-			maxJumpEntry = newMaxJumpEntry
-			jumpEntries = append(jumpEntries, uint16(maxJumpEntry))
-		} else {
-			break
-		}
-	}
-
-	mapIndex := 0x80
-
-	numEntries += maxJumpEntry - 1
-
-	for i := maxJumpEntry; i >= 2; i-- {
-		newCharMap.EntryTable[mapIndex] = uint16(i)
-		mapIndex++
-	}
-
-	for _, r := range chars {
-		newCharMap.EntryTable[mapIndex] = uint16(r)
-		mapIndex++
-	}
-
-	newCharMap.NumEntries = numEntries
-
-	return newCharMap
-}
-
-type LanguagePackJson struct {
-	Entries      map[string]string
-	SpecialChars []string
-}
-
 type Context struct {
 	//
 }
 
 //noinspection GoStructTag
 type UnpackCommand struct {
-	InputPath  string `arg name:"in" help:"Path to folder to read binary files from."`
-	OutputPath string `arg name:"out" help:"Path to folder to generate text files in."`
+	InputPath  string   `arg name:"in" help:"Path to folder to read binary files from."`
+	OutputPath string   `arg name:"out" help:"Path to folder to generate text files in."`
+	Dict       []string `help:"Label list files used to name hashes missing from the Labels file." type:"existingfile"`
+	Format     string   `help:"Text format to write. The csv format writes a file per language, use export-csv for a spreadsheet with a column per language." default:"json"`
 }
 
 //noinspection GoStructTag
@@ -88,6 +29,13 @@ type PackCommand struct {
 	InputPath  string `arg name:"in" help:"Path to folder to read text files from."`
 	OutputPath string `arg name:"out" help:"Path to folder to generate binary files in."`
 	Strict     bool   `help:"Enforce various validation rules (no unimplemented strings, no nonexistent strings, etc). Will result in some slowdown, but prevents stupid mistakes."`
+	Format     string `help:"Text format to read." default:"json"`
+
+	CharmapLayout string `help:"Charmap layout: 'frequency' keeps the used SpecialChars first and gives the most used other characters single byte codes except in packs merged by charmap-merge, 'ordered' keeps the SpecialChars order." enum:"frequency,ordered" default:"frequency"`
+	Normalize     string `help:"Unicode normalization applied to labels and strings before packing." enum:"nfc,nfkc,none" default:"nfc"`
+
+	Fallback         string `help:"What to do with characters that can't be encoded: 'error' fails, 'replace' substitutes '?', 'transliterate' uses the transliteration table." enum:"error,replace,transliterate" default:"error"`
+	Transliterations string `help:"JSON file mapping characters to their transliteration, replaces the built-in table." type:"existingfile"`
 }
 
 //noinspection GoStructTag
@@ -95,12 +43,16 @@ type AddStringCommand struct {
 	DataPath string `arg name:"in" help:"Path to folder with text files"`
 	Label    string `arg name:"label" help:"Label of the string to add"`
 	Text     string `arg name:"text" help:"Text of the string to add"`
+	Pack     string `help:"Pack to add the string to" default:"Global"`
+	Format   string `help:"Text format to read and write." default:"json"`
 }
 
 //noinspection GoStructTag
 type RemoveStringCommand struct {
 	DataPath string `arg name:"in" help:"Path to folder with text files"`
 	Label    string `arg name:"label" help:"Label of the string to remove"`
+	Pack     string `help:"Pack to remove the string from" default:"Global"`
+	Format   string `help:"Text format to read and write." default:"json"`
 }
 
 //noinspection GoStructTag
@@ -108,62 +60,52 @@ type HashCommand struct {
 	Value string `arg name:"value" help:"The string to hash"`
 }
 
-func (r *UnpackCommand) Run(_ *Context) error {
-	if _, err := os.Stat(r.OutputPath); os.IsNotExist(err) {
-		_ = os.Mkdir(r.OutputPath, 0644)
+// TextCodec returns the registered codec called name.
+func TextCodec(name string) (format.Codec, error) {
+	c, ok := format.Lookup(name)
+
+	if !ok {
+		return nil, fmt.Errorf("unknown format %s, available formats: %s", name, strings.Join(format.Names(), ", "))
 	}
 
-	labelsData, err := ioutil.ReadFile(path.Join(r.InputPath, "Labels_Global.bin"))
+	return c, nil
+}
+
+func (r *UnpackCommand) Run(_ *Context) error {
+	codec, err := TextCodec(r.Format)
 
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	labelsPack := lib.ParseFile(labelsData)
-	labelMap := make(map[uint32]string)
-
-	for _, e := range labelsPack.Entries {
-		labelMap[e.Hash] = e.String
+	if _, err := os.Stat(r.OutputPath); os.IsNotExist(err) {
+		_ = os.Mkdir(r.OutputPath, 0644)
 	}
 
-	matches, err := filepath.Glob(path.Join(r.InputPath, "*_Global.bin"))
+	packs, err := project.FindBinary(r.InputPath)
 
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	for _, fp := range matches {
-		_, fn := filepath.Split(fp)
-
-		if fn == "Largest_Global.bin" {
-			continue
+	dict := hashdict.New()
+	for _, fp := range r.Dict {
+		if err := dict.LoadFile(fp); err != nil {
+			return err
 		}
+	}
 
-		fmt.Println("Loading file:", fp)
-		enc, err := ioutil.ReadFile(fp)
-		if err != nil {
-			panic(err)
-		}
-		langFile := lib.ParseFile(enc)
-		fmt.Printf("Loaded %d strings from file\n", len(langFile.Entries))
-		cleanName := FilenameWithoutExtension(fn)
-		jsonName := path.Join(r.OutputPath, cleanName+".json")
-		langJson := &LanguagePackJson{
-			Entries:      make(map[string]string),
-			SpecialChars: make([]string, 0),
-		}
+	opts := &project.LoadOptions{Dict: dict, SkipInvalid: true, Logf: printf}
 
-		for _, e := range langFile.Entries {
-			langJson.Entries[labelMap[e.Hash]] = e.String
-		}
+	for _, pack := range packs {
+		p, err := project.LoadBinary(r.InputPath, pack, opts)
 
-		for _, c := range langFile.CharMap.EntryTable {
-			if c >= 0x80 {
-				langJson.SpecialChars = append(langJson.SpecialChars, string(rune(c)))
-			}
+		if err != nil {
+			fmt.Printf("Skipping pack %s: %v\n", pack, err)
+			continue
 		}
 
-		err = SaveLanguageJson(jsonName, langJson)
+		err = p.SaveText(r.OutputPath, codec)
 
 		if err != nil {
 			return err
@@ -173,151 +115,71 @@ func (r *UnpackCommand) Run(_ *Context) error {
 	return nil
 }
 
-func SaveLanguageJson(jsonName string, langJson *LanguagePackJson) error {
-	f, err := os.Create(jsonName)
-	if err != nil {
-		return err
-	}
-	encoder := json.NewEncoder(f)
-	encoder.SetEscapeHTML(false)
-	encoder.SetIndent("", " ")
-	err = encoder.Encode(langJson)
-	if err != nil {
-		return err
-	}
-
-	return nil
+// printf prints a line of progress.
+func printf(format string, a ...interface{}) {
+	fmt.Printf(format+"\n", a...)
 }
 
-func (r *PackCommand) Run(_ *Context) error {
-	if _, err := os.Stat(r.OutputPath); os.IsNotExist(err) {
-		_ = os.Mkdir(r.OutputPath, 0644)
+// options returns the pack options selected by the flags.
+func (r *PackCommand) options() (*project.PackOptions, error) {
+	opts := &project.PackOptions{
+		Strict:   r.Strict,
+		Fallback: &charmap.Fallback{Policy: FallbackPolicy(r.Fallback)},
+		Logf:     printf,
 	}
 
-	matches, err := filepath.Glob(path.Join(r.InputPath, "*_Global.json"))
-
-	if err != nil {
-		panic(err)
+	if r.CharmapLayout == "ordered" {
+		opts.Layout = project.LayoutOrdered
 	}
 
-	labelJson := LoadLanguageJson(path.Join(r.InputPath, "Labels_Global.json"))
-	labelPack := BuildLangFileFromJson(labelJson)
-
-	type SavePackEntry struct {
-		Name string
-		Pack *lib.LangFile
+	if form, ok := NormalizationForm(r.Normalize); ok {
+		opts.Normalize = &form
 	}
 
-	packs := []SavePackEntry{{
-		Name: "Labels_Global",
-		Pack: labelPack,
-	}}
-
-	for _, fp := range matches {
-		_, fn := filepath.Split(fp)
-
-		if strings.Contains(fn, "Labels") {
-			continue
-		}
-		cleanName := FilenameWithoutExtension(fn)
-		langJson := LoadLanguageJson(fp)
-		lp := BuildLangFileFromJson(langJson)
-
-		if r.Strict {
-			for _, e := range labelPack.Entries {
-				if lp.FindEntryByHash(e.Hash) == nil {
-					return fmt.Errorf("strict mode: pack %s does not have an entry for string %s", cleanName, e.String)
-				}
-			}
-
-			for l := range langJson.Entries {
-				if labelPack.FindEntryByName(l) == nil {
-					return fmt.Errorf("strict mode: pack %s has an entry for a nonexistent string (%s)", cleanName, l)
-				}
-			}
-		}
-
-		packs = append(packs, SavePackEntry{
-			Name: cleanName,
-			Pack: lp,
-		})
+	if r.Transliterations != "" {
+		table, err := LoadTransliterations(r.Transliterations)
 
-		fmt.Println("Loaded", len(langJson.Entries), "strings from", fp)
-	}
-
-	for _, p := range packs {
-		op := path.Join(r.OutputPath, p.Name+".bin")
-		fmt.Println("Saving", p.Name, "to", op)
-		f := lib.SaveFile(p.Pack, labelPack, true)
-		err = ioutil.WriteFile(op, f, 666)
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("failed to load transliterations: %v", err)
 		}
-		fmt.Println("Saved", p.Name, "to", op)
-	}
 
-	return nil
-}
-
-func (j *LanguagePackJson) AddString(label string, value string) error {
-	if _, exists := j.Entries[label]; exists {
-		return fmt.Errorf("string %s already exists in language pack", label)
+		opts.Fallback.Table = table
 	}
 
-	j.Entries[label] = value
-	return nil
+	return opts, nil
 }
 
-func (j *LanguagePackJson) RemoveString(label string) error {
-	if _, exists := j.Entries[label]; !exists {
-		return fmt.Errorf("string %s does not exist in language pack", label)
-	}
-	delete(j.Entries, label)
-	return nil
-}
-
-func (r *AddStringCommand) Run(_ *Context) error {
-	if _, err := os.Stat(r.DataPath); os.IsNotExist(err) {
-		_ = os.Mkdir(r.DataPath, 0644)
-	}
-
-	matches, err := filepath.Glob(path.Join(r.DataPath, "*_Global.json"))
+func (r *PackCommand) Run(_ *Context) error {
+	codec, err := TextCodec(r.Format)
 
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	labelJson := LoadLanguageJson(path.Join(r.DataPath, "Labels_Global.json"))
-
-	fmt.Println("Adding label")
-	err = labelJson.AddString(r.Label, r.Label)
+	opts, err := r.options()
 
 	if err != nil {
 		return err
 	}
 
-	err = SaveLanguageJson(path.Join(r.DataPath, "Labels_Global.json"), labelJson)
+	if _, err := os.Stat(r.OutputPath); os.IsNotExist(err) {
+		_ = os.Mkdir(r.OutputPath, 0644)
+	}
+
+	packs, err := format.FindProjects(r.InputPath, codec)
 
 	if err != nil {
 		return err
 	}
 
-	for _, fp := range matches {
-		_, fn := filepath.Split(fp)
-
-		if strings.Contains(fn, "Labels") {
-			continue
-		}
-		cleanName := FilenameWithoutExtension(fn)
-		fmt.Printf("Adding string to %s\n", cleanName)
-		langJson := LoadLanguageJson(fp)
-		err = langJson.AddString(r.Label, r.Text)
+	for _, pack := range packs {
+		p, err := project.LoadText(r.InputPath, pack, codec)
 
 		if err != nil {
 			return err
 		}
 
-		err = SaveLanguageJson(fp, langJson)
+		err = p.SaveBinary(r.OutputPath, opts)
 
 		if err != nil {
 			return err
@@ -327,111 +189,64 @@ func (r *AddStringCommand) Run(_ *Context) error {
 	return nil
 }
 
-func (r *HashCommand) Run(_ *Context) error {
-	hash := lib.BinHash(r.Value)
-	fmt.Printf("Hash of '%s': 0x%08x (%d)\n", r.Value, hash, hash)
-	return nil
-}
-
-func (r *RemoveStringCommand) Run(_ *Context) error {
-	if _, err := os.Stat(r.DataPath); os.IsNotExist(err) {
-		_ = os.Mkdir(r.DataPath, 0644)
-	}
-
-	matches, err := filepath.Glob(path.Join(r.DataPath, "*_Global.json"))
+func (r *AddStringCommand) Run(_ *Context) error {
+	codec, err := TextCodec(r.Format)
 
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	labelJson := LoadLanguageJson(path.Join(r.DataPath, "Labels_Global.json"))
-
-	fmt.Println("Removing label")
-	err = labelJson.RemoveString(r.Label)
+	p, err := project.LoadText(r.DataPath, r.Pack, codec)
 
 	if err != nil {
 		return err
 	}
 
-	err = SaveLanguageJson(path.Join(r.DataPath, "Labels_Global.json"), labelJson)
+	err = p.AddString(r.Label, r.Text)
 
 	if err != nil {
 		return err
 	}
 
-	for _, fp := range matches {
-		_, fn := filepath.Split(fp)
-
-		if strings.Contains(fn, "Labels") {
-			continue
-		}
-		cleanName := FilenameWithoutExtension(fn)
-		fmt.Printf("Removing string from %s\n", cleanName)
-		langJson := LoadLanguageJson(fp)
-		err = langJson.RemoveString(r.Label)
-
-		if err != nil {
-			return err
-		}
-
-		err = SaveLanguageJson(fp, langJson)
-
-		if err != nil {
-			return err
-		}
+	fmt.Println("Adding label")
+	for _, lang := range p.EditableLanguages() {
+		fmt.Printf("Adding string to %s_%s\n", lang, r.Pack)
 	}
 
-	return nil
+	return p.SaveText(r.DataPath, codec)
 }
 
-func BuildLangFileFromJson(langJson *LanguagePackJson) *lib.LangFile {
-	specialChars := make([]rune, 0)
+func (r *HashCommand) Run(_ *Context) error {
+	hash := lib.BinHash(r.Value)
+	fmt.Printf("Hash of '%s': %s (%d)\n", r.Value, lib.HexKey(hash), hash)
+	return nil
+}
 
-	for _, e := range langJson.SpecialChars {
-		var first rune
-		for _, c := range e {
-			first = c
-			break
-		}
+func (r *RemoveStringCommand) Run(_ *Context) error {
+	codec, err := TextCodec(r.Format)
 
-		specialChars = append(specialChars, first)
+	if err != nil {
+		return err
 	}
 
-	entries := make([]lib.LangFileEntry, 0)
+	p, err := project.LoadText(r.DataPath, r.Pack, codec)
 
-	for l, e := range langJson.Entries {
-		entries = append(entries, lib.LangFileEntry{
-			Hash:          lib.BinHash(l),
-			String:        e,
-			Offset:        0,
-			OriginalBytes: nil,
-		})
-	}
-
-	lp := &lib.LangFile{
-		Entries: entries,
-		CharMap: BuildCharMap(specialChars),
+	if err != nil {
+		return err
 	}
-	return lp
-}
 
-func LoadLanguageJson(fp string) *LanguagePackJson {
-	f, err := os.Open(fp)
+	err = p.RemoveString(r.Label)
 
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	decoder := json.NewDecoder(f)
-	decoder.DisallowUnknownFields()
-	langJson := &LanguagePackJson{}
-	err = decoder.Decode(&langJson)
-
-	if err != nil {
-		panic(err)
+	fmt.Println("Removing label")
+	for _, lang := range p.LanguageNames() {
+		fmt.Printf("Removing string from %s_%s\n", lang, r.Pack)
 	}
 
-	return langJson
+	return p.SaveText(r.DataPath, codec)
 }
 
 //noinspection GoStructTag
@@ -441,10 +256,21 @@ var cli struct {
 	AddString    AddStringCommand    `cmd help:"Add a string."`
 	RemoveString RemoveStringCommand `cmd help:"Remove a string."`
 	Hash         HashCommand         `cmd help:"Calculate the hash of a string."`
+	Charmap      CharmapCommand      `cmd help:"Show the charmap of a binary file."`
+	CharmapMerge CharmapMergeCommand `cmd help:"Share one charmap between language packs."`
+	Crack        CrackCommand        `cmd help:"Recover labels of unlabeled hashes."`
+	Collisions   CollisionsCommand   `cmd help:"List labels that share a hash."`
+	Inspect      InspectCommand      `cmd help:"Show the structure of a binary file."`
+	ExportPo     ExportPoCommand     `cmd help:"Export a pack to gettext PO files."`
+	ImportPo     ImportPoCommand     `cmd help:"Import translations from gettext PO files."`
+	ExportXliff  ExportXliffCommand  `cmd help:"Export a language to an XLIFF file."`
+	ImportXliff  ImportXliffCommand  `cmd help:"Import approved translations from an XLIFF file."`
+	ExportCsv    ExportCsvCommand    `cmd help:"Export a pack to a spreadsheet with a column per language, unlike the per-language files of --format csv."`
+	ImportCsv    ImportCsvCommand    `cmd help:"Import changed strings from a spreadsheet."`
 }
 
 func main() {
-	fmt.Println("JsonTool v2.0.3 by heyitsleo")
+	fmt.Fprintln(os.Stderr, "JsonTool v2.0.3 by heyitsleo")
 	ctx := kong.Parse(&cli)
 	// Call the Run() method of the selected parsed command.
 	err := ctx.Run(&Context{})
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/charmap"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/format"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/project"
+)
+
+//noinspection GoStructTag
+type CharmapMergeCommand struct {
+	Files  []string `arg name:"files" help:"Language files to share a charmap, in any text format." type:"existingfile"`
+	DryRun bool     `help:"Only report the merged charmap, don't rewrite the files."`
+}
+
+func (r *CharmapMergeCommand) Run(_ *Context) error {
+	packs := make([]*format.Pack, len(r.Files))
+	for i, fp := range r.Files {
+		lp, err := format.ReadPackFile(fp)
+
+		if err != nil {
+			return err
+		}
+
+		packs[i] = lp
+	}
+
+	cm, err := project.MergeCharMaps(packs)
+
+	if err != nil {
+		return fmt.Errorf("failed to merge charmaps: %v", err)
+	}
+
+	capacity := charmap.PlanCapacity(len(cm.Entries()))
+	fmt.Printf("Merged charmap of %d packs: %d characters (%d single byte, %d two byte), %d entries free\n",
+		len(r.Files), capacity.Runes, capacity.Runes-capacity.TwoByteRunes, capacity.TwoByteRunes, capacity.Headroom)
+
+	if r.DryRun {
+		return nil
+	}
+
+	for i, fp := range r.Files {
+		if err := format.WritePackFile(fp, packs[i]); err != nil {
+			return err
+		}
+		fmt.Println("Rewrote SpecialChars of", fp)
+	}
+
+	return nil
+}
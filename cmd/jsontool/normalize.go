@@ -0,0 +1,14 @@
+package main
+
+import "golang.org/x/text/unicode/norm"
+
+func NormalizationForm(name string) (norm.Form, bool) {
+	switch name {
+	case "nfc":
+		return norm.NFC, true
+	case "nfkc":
+		return norm.NFKC, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"github.com/WorldUnitedNFS/worldlangedit/lib"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/po"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/project"
+	"os"
+	"path"
+)
+
+//noinspection GoStructTag
+type ExportPoCommand struct {
+	DataPath   string `arg name:"in" help:"Path to folder with text files"`
+	OutputPath string `arg name:"out" help:"Path to folder to write PO files to"`
+	Pack       string `help:"Pack to export" default:"Global"`
+	Source     string `help:"Language the msgids are taken from" default:"English"`
+	Format     string `help:"Text format to read." default:"json"`
+}
+
+//noinspection GoStructTag
+type ImportPoCommand struct {
+	DataPath string   `arg name:"in" help:"Path to folder with text files"`
+	Files    []string `arg name:"files" help:"PO files named <Language>_<Pack>.po" type:"existingfile"`
+	Source   string   `help:"Language the msgids were taken from" default:"English"`
+	Fuzzy    bool     `help:"Also apply translations marked fuzzy."`
+	Force    bool     `help:"Apply translations whose source text changed since the export."`
+	Format   string   `help:"Text format to read and write." default:"json"`
+}
+
+func writePo(fp string, f *po.File) error {
+	out, err := os.Create(fp)
+
+	if err != nil {
+		return err
+	}
+
+	err = f.Write(out)
+
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func (r *ExportPoCommand) Run(_ *Context) error {
+	codec, err := TextCodec(r.Format)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(r.OutputPath); os.IsNotExist(err) {
+		_ = os.Mkdir(r.OutputPath, 0755)
+	}
+
+	p, err := project.LoadText(r.DataPath, r.Pack, codec)
+
+	if err != nil {
+		return err
+	}
+
+	pot, err := p.ExportPo(r.Source, "")
+
+	if err != nil {
+		return err
+	}
+
+	potPath := path.Join(r.OutputPath, r.Pack+".pot")
+	if err := writePo(potPath, pot); err != nil {
+		return err
+	}
+	fmt.Println("Wrote template", potPath)
+
+	for _, lang := range p.LanguageNames() {
+		if lang == project.LargestLanguage || lang == r.Source {
+			continue
+		}
+
+		poPath := path.Join(r.OutputPath, lang+"_"+r.Pack+".po")
+		f, err := p.ExportPo(r.Source, lang)
+
+		if err != nil {
+			return err
+		}
+
+		if err := writePo(poPath, f); err != nil {
+			return err
+		}
+
+		fmt.Println("Wrote", poPath)
+	}
+
+	return nil
+}
+
+func (r *ImportPoCommand) Run(_ *Context) error {
+	codec, err := TextCodec(r.Format)
+
+	if err != nil {
+		return err
+	}
+
+	for _, fp := range r.Files {
+		lang, pack, ok := lib.SplitFileName(fp)
+
+		if !ok {
+			return fmt.Errorf("%s is not named <Language>_<Pack>.po", fp)
+		}
+
+		in, err := os.Open(fp)
+
+		if err != nil {
+			return err
+		}
+
+		f, err := po.Parse(in)
+		_ = in.Close()
+
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %v", fp, err)
+		}
+
+		p, err := project.LoadText(r.DataPath, pack, codec)
+
+		if err != nil {
+			return err
+		}
+
+		res, err := p.ImportPo(f, r.Source, lang, r.Fuzzy, r.Force)
+
+		if err != nil {
+			return fmt.Errorf("failed to import %s: %v", fp, err)
+		}
+
+		for _, c := range res.Conflicts {
+			fmt.Printf("%s: conflict in %s: %s\n", fp, c.Label, c.Reason)
+		}
+
+		fmt.Printf("%s: %d strings updated, %d fuzzy and %d obsolete entries skipped, %d conflicts\n",
+			lang+"_"+pack, res.Updated, res.Fuzzy, res.Obsolete, len(res.Conflicts))
+
+		if res.Updated > 0 {
+			if err := p.SaveLanguages(r.DataPath, codec, lang); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
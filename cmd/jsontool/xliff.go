@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/project"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/xliff"
+	"os"
+)
+
+//noinspection GoStructTag
+type ExportXliffCommand struct {
+	DataPath   string `arg name:"in" help:"Path to folder with text files"`
+	Language   string `arg name:"lang" help:"Language to translate into"`
+	Output     string `arg name:"out" help:"XLIFF file to write"`
+	Pack       string `help:"Pack to export" default:"Global"`
+	Source     string `help:"Language to translate from" default:"English"`
+	Version    string `help:"XLIFF version to write." enum:"1.2,2.0" default:"1.2"`
+	SourceCode string `help:"Language code written for the source language, defaults to its name."`
+	TargetCode string `help:"Language code written for the target language, defaults to its name."`
+	Format     string `help:"Text format to read." default:"json"`
+}
+
+//noinspection GoStructTag
+type ImportXliffCommand struct {
+	DataPath   string `arg name:"in" help:"Path to folder with text files"`
+	File       string `arg name:"file" help:"XLIFF file to import" type:"existingfile"`
+	Language   string `arg name:"lang" help:"Language to apply the translations to"`
+	Pack       string `help:"Pack to import into, defaults to the original of the XLIFF file"`
+	Source     string `help:"Language that was translated from" default:"English"`
+	Unapproved bool   `help:"Also apply translations that are not approved."`
+	Force      bool   `help:"Apply translations whose source text changed since the export."`
+	Format     string `help:"Text format to read and write." default:"json"`
+}
+
+func LoadXliff(fp string) (*xliff.Document, error) {
+	f, err := os.Open(fp)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	return xliff.Read(f)
+}
+
+func SaveXliff(fp string, doc *xliff.Document) error {
+	f, err := os.Create(fp)
+
+	if err != nil {
+		return err
+	}
+
+	err = doc.Write(f)
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func (r *ExportXliffCommand) Run(_ *Context) error {
+	codec, err := TextCodec(r.Format)
+
+	if err != nil {
+		return err
+	}
+
+	p, err := project.LoadText(r.DataPath, r.Pack, codec)
+
+	if err != nil {
+		return err
+	}
+
+	doc, err := p.ExportXliff(r.Source, r.Language)
+
+	if err != nil {
+		return err
+	}
+
+	doc.Version = r.Version
+
+	if r.SourceCode != "" {
+		doc.SourceLanguage = r.SourceCode
+	}
+
+	if r.TargetCode != "" {
+		doc.TargetLanguage = r.TargetCode
+	}
+
+	if err := SaveXliff(r.Output, doc); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %d units to %s\n", len(doc.Units), r.Output)
+	return nil
+}
+
+func (r *ImportXliffCommand) Run(_ *Context) error {
+	codec, err := TextCodec(r.Format)
+
+	if err != nil {
+		return err
+	}
+
+	doc, err := LoadXliff(r.File)
+
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", r.File, err)
+	}
+
+	pack := r.Pack
+	if pack == "" {
+		pack = doc.Original
+	}
+	if pack == "" {
+		pack = "Global"
+	}
+
+	p, err := project.LoadText(r.DataPath, pack, codec)
+
+	if err != nil {
+		return err
+	}
+
+	res, err := p.ImportXliff(doc, r.Source, r.Language, r.Unapproved, r.Force)
+
+	if err != nil {
+		return err
+	}
+
+	for _, c := range res.Conflicts {
+		fmt.Printf("%s: conflict in %s: %s\n", r.File, c.Label, c.Reason)
+	}
+
+	fmt.Printf("%s: %d strings and %d notes updated, %d unapproved translations skipped, %d conflicts\n",
+		r.Language+"_"+pack, res.Updated, res.Notes, res.Unapproved, len(res.Conflicts))
+
+	if res.Updated > 0 || res.Notes > 0 {
+		return p.SaveLanguages(r.DataPath, codec, r.Language)
+	}
+
+	return nil
+}
@@ -5,16 +5,17 @@
 package main
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
 	"path"
-	"sort"
 	"strings"
 
 	"github.com/lxn/walk"
 	. "github.com/lxn/walk/declarative"
 
 	"github.com/WorldUnitedNFS/worldlangedit/lib"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/project"
 )
 
 var win *walk.MainWindow
@@ -64,45 +65,32 @@ func UpdateShownTableEntries() {
 
 func toolOpenTriggered() {
 	d := &walk.FileDialog{
-		Filter: "Language files|*_Global.bin",
+		Filter: "Language files|*_*.bin",
 	}
 	acc, _ := d.ShowOpen(win)
 	if !acc {
 		return
 	}
 	langFilePath = d.FilePath
-	enc, err := ioutil.ReadFile(d.FilePath)
+	var err error
+	langFile, err = project.LoadLangFile(d.FilePath)
 	if err != nil {
-		walk.MsgBox(win, "Error", "Failed to open language file", walk.MsgBoxIconError)
+		walk.MsgBox(win, "Error", "Failed to open language file: "+err.Error(), walk.MsgBoxIconError)
 		return
 	}
-	langFile = lib.ParseFile(enc)
-	labelsFilePath = path.Join(path.Dir(d.FilePath), "Labels_Global.bin")
-	enc, err = ioutil.ReadFile(labelsFilePath)
+	_, pack, _ := lib.SplitFileName(d.FilePath)
+	labelsName := project.LabelsLanguage + "_" + pack + ".bin"
+	labelsFilePath = path.Join(path.Dir(d.FilePath), labelsName)
+	labelsFile, err = project.LoadLangFile(labelsFilePath)
 	if err != nil {
-		walk.MsgBox(win, "Error", "Failed to open Labels_Global.bin", walk.MsgBoxIconError)
+		walk.MsgBox(win, "Error", "Failed to open "+labelsName+": "+err.Error(), walk.MsgBoxIconError)
 		return
 	}
-	labelsFile = lib.ParseFile(enc)
-	entriesMap := make(map[uint32]TableEntry)
-	for _, entry := range labelsFile.Entries {
-		sct := entriesMap[entry.Hash]
-		sct.Label = entry.String
-		entriesMap[entry.Hash] = sct
+	rows := project.Join(labelsFile, langFile)
+	entries := make([]TableEntry, len(rows))
+	for i, r := range rows {
+		entries[i] = TableEntry{Hash: r.Hash, Label: r.Label, Translation: r.Translation}
 	}
-	for _, entry := range langFile.Entries {
-		sct := entriesMap[entry.Hash]
-		sct.Translation = entry.String
-		entriesMap[entry.Hash] = sct
-	}
-	entries := make([]TableEntry, len(entriesMap))
-	i := 0
-	for h, e := range entriesMap {
-		e.Hash = h
-		entries[i] = e
-		i++
-	}
-	sort.Slice(entries, func(i, j int) bool { return entries[i].Hash < entries[j].Hash })
 	tableEntries = entries
 	UpdateShownTableEntries()
 	err = logStatus.SetText("File opened")
@@ -198,45 +186,8 @@ func tableItemActivated() {
 							}
 							UpdateShownTableEntries()
 
-							updatedEntry := false
-
-							for i, e := range langFile.Entries {
-								if e.Hash == origHash {
-									langFile.Entries[i].Hash = entry.Hash
-									langFile.Entries[i].String = entry.Translation
-									updatedEntry = true
-									break
-								}
-							}
-
-							if !updatedEntry {
-								langFile.Entries = append(langFile.Entries, lib.LangFileEntry{
-									Hash:          entry.Hash,
-									String:        entry.Translation,
-									Offset:        0,
-									OriginalBytes: nil,
-								})
-							}
-
-							updatedLabel := false
-
-							for i, e := range labelsFile.Entries {
-								if e.Hash == origHash {
-									labelsFile.Entries[i].Hash = entry.Hash
-									labelsFile.Entries[i].String = entry.Label
-									updatedLabel = true
-									break
-								}
-							}
-
-							if !updatedLabel {
-								labelsFile.Entries = append(labelsFile.Entries, lib.LangFileEntry{
-									Hash:          entry.Hash,
-									String:        entry.Label,
-									Offset:        0,
-									OriginalBytes: nil,
-								})
-							}
+							langFile.Set(entry.Hash, entry.Translation)
+							labelsFile.Set(entry.Hash, entry.Label)
 
 							dlg.Accept()
 						},
@@ -260,22 +211,29 @@ func BackupFileIfNeeded(path string) {
 	}
 }
 
+// saveLangFile writes file to path, keeping the layout of the original file
+// and a backup of it.
+func saveLangFile(path string, file *lib.LangFile) error {
+	var buf bytes.Buffer
+	if err := lib.EncodePreserving(&buf, file, true); err != nil {
+		return err
+	}
+	BackupFileIfNeeded(path)
+	return ioutil.WriteFile(path, buf.Bytes(), 666)
+}
+
 func toolSaveTriggered() {
-	f := lib.SaveFile(langFile, labelsFile, true)
-	BackupFileIfNeeded(langFilePath)
-	err := ioutil.WriteFile(langFilePath, f, 666)
-	if err != nil {
-		panic(err)
+	if err := saveLangFile(langFilePath, langFile); err != nil {
+		walk.MsgBox(win, "Error", "Failed to save language file: "+err.Error(), walk.MsgBoxIconError)
+		return
 	}
 	if labelsEdited {
-		f = lib.SaveFile(labelsFile, labelsFile, true)
-		BackupFileIfNeeded(labelsFilePath)
-		err = ioutil.WriteFile(labelsFilePath, f, 666)
-		if err != nil {
-			panic(err)
+		if err := saveLangFile(labelsFilePath, labelsFile); err != nil {
+			walk.MsgBox(win, "Error", "Failed to save labels file: "+err.Error(), walk.MsgBoxIconError)
+			return
 		}
 	}
-	err = logStatus.SetText("File saved")
+	err := logStatus.SetText("File saved")
 
 	if err != nil {
 		panic(err)
@@ -347,16 +305,15 @@ func toolAddTriggered() {
 								panic(err)
 							}
 
+							if err := project.CheckNewLabel(labelsFile, langFile, entry.Label); err != nil {
+								walk.MsgBox(dlg, "Error", err.Error(), walk.MsgBoxIconError)
+								return
+							}
+
 							tableEntries = append(tableEntries, entry)
 							UpdateShownTableEntries()
-							langFile.Entries = append(langFile.Entries, lib.LangFileEntry{
-								Hash:   entry.Hash,
-								String: entry.Translation,
-							})
-							labelsFile.Entries = append(labelsFile.Entries, lib.LangFileEntry{
-								Hash:   entry.Hash,
-								String: entry.Label,
-							})
+							langFile.Set(entry.Hash, entry.Translation)
+							labelsFile.Set(entry.Hash, entry.Label)
 							labelsEdited = true
 
 							dlg.Accept()
@@ -4,6 +4,8 @@
 
 package lib
 
+import "fmt"
+
 func BinHash(s string) uint32 {
 	if len(s) == 0 {
 		return 0
@@ -15,3 +17,8 @@ func BinHash(s string) uint32 {
 	}
 	return hash
 }
+
+// HexKey returns the key a hash without a label is shown and stored under.
+func HexKey(hash uint32) string {
+	return fmt.Sprintf("0x%08X", hash)
+}
@@ -0,0 +1,188 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package charmap
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TableSize is the number of entries in a charmap table.
+const TableSize = 0xC00
+
+// TooManyRunesError is returned when a rune set does not fit into the table.
+// Overflow holds the runes past the last one that fits.
+type TooManyRunesError struct {
+	Entries  int
+	Overflow []rune
+}
+
+func (e *TooManyRunesError) Error() string {
+	const maxListed = 50
+	listed := e.Overflow
+	if len(listed) > maxListed {
+		listed = listed[:maxListed]
+	}
+	msg := fmt.Sprintf("charmap needs %d entries, but the table only holds %d; characters that don't fit: %s",
+		e.Entries, TableSize, string(listed))
+	if len(e.Overflow) > maxListed {
+		msg += fmt.Sprintf(" and %d more", len(e.Overflow)-maxListed)
+	}
+	return msg
+}
+
+// Capacity describes how a rune set is laid out in a charmap table.
+type Capacity struct {
+	Runes int
+	// JumpPages is the number of jump entries at the start of the single
+	// byte range.
+	JumpPages int
+	// SingleByteSlots is the number of single byte slots left for runes.
+	SingleByteSlots int
+	// TwoByteRunes is the number of runes that need two bytes.
+	TwoByteRunes int
+	// Entries is the NumEntries of the resulting table.
+	Entries int
+	// Headroom is the number of free table entries. It is negative if the
+	// runes don't fit.
+	Headroom int
+	// MaxRunes is the number of runes that fit into the table.
+	MaxRunes int
+}
+
+// pages returns the highest page used by a table holding n runes.
+func pages(n int) int {
+	tmpNumEntries := 0x80 + n
+
+	maxJumpEntry := tmpNumEntries >> 7
+
+	if maxJumpEntry >= 2 {
+		tmpNumEntries++
+	}
+
+	// Determine jump entries
+	for {
+		newMaxJumpEntry := tmpNumEntries >> 7
+
+		if newMaxJumpEntry > maxJumpEntry {
+			tmpNumEntries++
+			maxJumpEntry = newMaxJumpEntry
+		} else {
+			break
+		}
+	}
+
+	return maxJumpEntry
+}
+
+// entries returns the NumEntries of a table holding n runes.
+func entries(n int) int {
+	return 0x80 + n + pages(n) - 1
+}
+
+// maxRunes is the largest number of runes that fit into the table.
+var maxRunes = func() int {
+	n := TableSize - 0x80
+	for entries(n) > TableSize {
+		n--
+	}
+	return n
+}()
+
+// PlanCapacity returns the layout of a table holding n runes.
+func PlanCapacity(n int) Capacity {
+	jumps := pages(n) - 1
+	single := 0x80 - jumps
+	twoByte := n - single
+	if twoByte < 0 {
+		twoByte = 0
+	}
+
+	return Capacity{
+		Runes:           n,
+		JumpPages:       jumps,
+		SingleByteSlots: single,
+		TwoByteRunes:    twoByte,
+		Entries:         entries(n),
+		Headroom:        TableSize - entries(n),
+		MaxRunes:        maxRunes,
+	}
+}
+
+// Fits reports whether the runes fit into the table.
+func (c Capacity) Fits() bool {
+	return c.Headroom >= 0
+}
+
+// Build lays out a charmap for the given runes. The first runes get the single
+// byte slots, the rest are reached through jump entries.
+func Build(chars []rune) (*Charmap, error) {
+	for _, r := range chars {
+		if !storable(r) {
+			return nil, fmt.Errorf("character %c (%U) cannot be stored in a charmap", r, r)
+		}
+	}
+
+	capacity := PlanCapacity(len(chars))
+	if !capacity.Fits() {
+		return nil, &TooManyRunesError{
+			Entries:  capacity.Entries,
+			Overflow: chars[capacity.MaxRunes:],
+		}
+	}
+
+	newCharMap := &Charmap{
+		NumEntries: int32(capacity.Entries),
+		EntryTable: [TableSize]uint16{},
+	}
+
+	mapIndex := 0x80
+
+	for i := capacity.JumpPages + 1; i >= 2; i-- {
+		newCharMap.EntryTable[mapIndex] = uint16(i)
+		mapIndex++
+	}
+
+	for _, r := range chars {
+		newCharMap.EntryTable[mapIndex] = uint16(r)
+		mapIndex++
+	}
+
+	return newCharMap, nil
+}
+
+// Runes returns the runes a charmap needs to encode strs: the non-ASCII runes
+// of pinned in their order, followed by every other non-ASCII rune used in
+// strs in ascending order. Runes that can't be stored in a charmap are left
+// out, encoding them reports an *EncodeError.
+func Runes(pinned []rune, strs []string) []rune {
+	seen := make(map[rune]bool)
+	chars := make([]rune, 0)
+
+	for _, c := range pinned {
+		if storable(c) && !seen[c] {
+			seen[c] = true
+			chars = append(chars, c)
+		}
+	}
+
+	used := make([]rune, 0)
+	for _, s := range strs {
+		for _, c := range s {
+			if storable(c) && !seen[c] {
+				seen[c] = true
+				used = append(used, c)
+			}
+		}
+	}
+	sort.Slice(used, func(i, j int) bool { return used[i] < used[j] })
+
+	return append(chars, used...)
+}
+
+// storable reports whether c can be stored in the table.
+func storable(c rune) bool {
+	return c >= 0x80 && c < 0xFF80
+}
@@ -0,0 +1,131 @@
+package charmap_test
+
+import (
+	"testing"
+
+	"github.com/WorldUnitedNFS/worldlangedit/lib/charmap"
+)
+
+func cjkString(n int) string {
+	runes := make([]rune, n)
+	for i := range runes {
+		runes[i] = rune(0x4E00 + i)
+	}
+	return string(runes)
+}
+
+func TestBuildRoundTrip(t *testing.T) {
+	str := "Hello " + cjkString(1000) + " ü"
+	cm, err := charmap.Build(charmap.Runes([]rune{'ü'}, []string{str}))
+
+	if err != nil {
+		t.Fatalf("Failed to build charmap: %v", err)
+	}
+	if cm.EntryTable[0x80+7] != 'ü' {
+		t.Errorf("Pinned rune is not first after the jump entries")
+	}
+
+	decoded := cm.DecodeBytes(cm.EncodeString(str, nil))
+	if decoded != str {
+		t.Errorf("Round trip changed the string")
+	}
+}
+
+func TestBuildTooManyRunes(t *testing.T) {
+	runes := charmap.Runes(nil, []string{cjkString(charmap.TableSize)})
+	_, err := charmap.Build(runes)
+
+	tmr, ok := err.(*charmap.TooManyRunesError)
+	if !ok {
+		t.Fatalf("Expected *TooManyRunesError, got %v", err)
+	}
+
+	capacity := charmap.PlanCapacity(len(runes))
+	if capacity.Fits() || len(tmr.Overflow) != len(runes)-capacity.MaxRunes {
+		t.Errorf("Expected %d runes to overflow, got %d", len(runes)-capacity.MaxRunes, len(tmr.Overflow))
+	}
+	if tmr.Overflow[0] != runes[capacity.MaxRunes] {
+		t.Errorf("Overflow does not start with the first rune that doesn't fit")
+	}
+
+	if _, err := charmap.Build(runes[:capacity.MaxRunes]); err != nil {
+		t.Errorf("Failed to build charmap with MaxRunes runes: %v", err)
+	}
+}
+
+func TestPlanCapacity(t *testing.T) {
+	c := charmap.PlanCapacity(100)
+	if c.JumpPages != 0 || c.SingleByteSlots != 128 || c.TwoByteRunes != 0 || c.Headroom != charmap.TableSize-228 {
+		t.Errorf("Unexpected capacity for 100 runes: %+v", c)
+	}
+
+	c = charmap.PlanCapacity(1000)
+	if c.JumpPages != 7 || c.SingleByteSlots != 121 || c.TwoByteRunes != 879 || c.Entries != 1135 {
+		t.Errorf("Unexpected capacity for 1000 runes: %+v", c)
+	}
+}
+
+func TestRunesByFrequency(t *testing.T) {
+	strs := []string{"äöü", "üüü", "öö"}
+	runes := charmap.RunesByFrequency([]rune{'ß', 'ä'}, strs)
+	expected := []rune{'ä', 'ü', 'ö', 'ß'}
+
+	if string(runes) != string(expected) {
+		t.Errorf("Expected %q, got %q", string(expected), string(runes))
+	}
+
+	rare := cjkString(300)
+	common := string([]rune(rare)[299:])
+	strs = []string{rare, common, common, common}
+	ordered, _ := charmap.Build(charmap.Runes(nil, strs))
+	frequent, _ := charmap.Build(charmap.RunesByFrequency(nil, strs))
+	if frequent.EncodedSize(strs) >= ordered.EncodedSize(strs) {
+		t.Errorf("Frequency layout is not smaller: %d >= %d", frequent.EncodedSize(strs), ordered.EncodedSize(strs))
+	}
+}
+
+func TestMerge(t *testing.T) {
+	chars, cm, err := charmap.Merge([][]rune{{'ß'}, nil}, [][]string{{"äöü"}, {"ééé", "ö"}})
+	if err != nil {
+		t.Fatalf("Failed to merge: %v", err)
+	}
+	if string(chars) != "éöäüß" {
+		t.Errorf("Expected éöäüß, got %q", string(chars))
+	}
+	if !cm.CanEncode("ßäöüé") {
+		t.Errorf("Merged charmap is missing characters")
+	}
+
+	half := []rune(cjkString(2000))
+	_, _, err = charmap.Merge(nil, [][]string{{string(half[:1500])}, {string(half[500:])}})
+	if err != nil {
+		t.Errorf("Expected overlapping sets to fit, got %v", err)
+	}
+	_, _, err = charmap.Merge(nil, [][]string{{cjkString(2000)}, {cjkString(4000)}})
+	if _, ok := err.(*charmap.TooManyRunesError); !ok {
+		t.Errorf("Expected TooManyRunesError, got %v", err)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	cm, err := charmap.Build([]rune(cjkString(1000)))
+	if err != nil {
+		t.Fatalf("Failed to build charmap: %v", err)
+	}
+
+	l := cm.Inspect()
+	if len(l.Jumps) != 7 || l.Jumps[0].Page != 8 || l.Jumps[6].Page != 2 {
+		t.Errorf("Unexpected jump entries: %v", l.Jumps)
+	}
+	if len(l.SingleByte) != 128-7 || len(l.TwoByte) != 1000-121 {
+		t.Errorf("Expected 121 single and 879 two byte runes, got %d and %d", len(l.SingleByte), len(l.TwoByte))
+	}
+	if l.Free != charmap.TableSize-l.NumEntries {
+		t.Errorf("Expected %d free entries, got %d", charmap.TableSize-l.NumEntries, l.Free)
+	}
+	for _, e := range l.TwoByte {
+		if len(e.Bytes) != 2 || cm.DecodeBytes(e.Bytes) != string(e.Rune) {
+			t.Errorf("Bytes %v of %c do not decode back to it", e.Bytes, e.Rune)
+		}
+	}
+}
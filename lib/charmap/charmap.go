@@ -7,111 +7,215 @@ package charmap
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 )
 
+// ChunkSize is the size of a serialized Charmap.
+const ChunkSize = 4 + 0xC00*2
+
+var ErrShortChunk = errors.New("charmap chunk too short")
+
 type Charmap struct {
 	NumEntries int32
 	EntryTable [0xC00]uint16
 }
 
 func FromChunk(chunk []byte) *Charmap {
+	cm, err := ParseChunk(chunk)
+	if err != nil {
+		panic(err)
+	}
+	return cm
+}
+
+func ParseChunk(chunk []byte) (*Charmap, error) {
+	if len(chunk) < ChunkSize {
+		return nil, ErrShortChunk
+	}
 	buf := bytes.NewBuffer(chunk)
 	cm := &Charmap{}
 	err := binary.Read(buf, binary.LittleEndian, cm)
+	if err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+// ChunkData serializes the charmap into the payload of a charmap chunk.
+func (cm *Charmap) ChunkData() []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, ChunkSize))
+	err := binary.Write(buf, binary.LittleEndian, cm)
 	if err != nil {
 		panic(err)
 	}
-	return cm
+	return buf.Bytes()
+}
+
+// DecodeError is returned when a byte sequence can't be decoded. Offset is
+// the position of the offending byte. Label is left for the caller to fill in.
+type DecodeError struct {
+	Offset int
+	Byte   byte
+	Label  string
+}
+
+func (e *DecodeError) Error() string {
+	msg := fmt.Sprintf("could not decode byte 0x%02x at offset %d", e.Byte, e.Offset)
+	if e.Label != "" {
+		msg += " in " + e.Label
+	}
+	return msg
+}
+
+// EncodeError is returned when a rune is not in the charmap. Pos is the index
+// of the rune in the string. Label is left for the caller to fill in.
+type EncodeError struct {
+	Rune  rune
+	Pos   int
+	Label string
+}
+
+func (e *EncodeError) Error() string {
+	msg := fmt.Sprintf("could not encode character %c (%U) at position %d", e.Rune, e.Rune, e.Pos)
+	if e.Label != "" {
+		msg += " in " + e.Label
+	}
+	return msg
 }
 
 func (cm *Charmap) DecodeBytes(b []byte) string {
+	s, err := cm.Decode(b)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Decode is like DecodeBytes, but returns a *DecodeError instead of
+// panicking. Only the first NumEntries entries of the table are used, the
+// same ones Encode searches, so every decoded string can be encoded again.
+func (cm *Charmap) Decode(b []byte) (string, error) {
 	runes := make([]rune, 0)
+	used := cm.usedEntries()
 
 	for i := 0; i < len(b); {
 		curByte := rune(b[i])
 		i++
 
 		if curByte >= 0x80 {
+			if int(curByte) >= used {
+				return "", &DecodeError{Offset: i - 1, Byte: b[i-1]}
+			}
 			histEntry := rune(cm.EntryTable[curByte])
 
 			if histEntry >= 0x80 {
 				curByte = histEntry
-				//fmt.Printf("encountered: %c\n", curByte)
 			} else if histEntry != 0 {
+				if i >= len(b) {
+					return "", &DecodeError{Offset: i - 1, Byte: b[i-1]}
+				}
 				nextByte := b[i]
 				i++
-				if nextByte >= 0x80 {
-					curByte = rune(cm.EntryTable[128*histEntry-128+rune(nextByte)])
-					//fmt.Printf("encountered: %c\n", curByte)
+				if nextByte < 0x80 {
+					return "", &DecodeError{Offset: i - 1, Byte: nextByte}
 				}
+				idx := 128*histEntry - 128 + rune(nextByte)
+				if int(idx) >= used || cm.EntryTable[idx] < 0x80 {
+					return "", &DecodeError{Offset: i - 1, Byte: nextByte}
+				}
+				curByte = rune(cm.EntryTable[idx])
 			} else {
-				panic("Could not decode string")
+				return "", &DecodeError{Offset: i - 1, Byte: b[i-1]}
+			}
+
+			if !storable(curByte) {
+				return "", &DecodeError{Offset: i - 1, Byte: b[i-1]}
 			}
 		}
 
 		runes = append(runes, curByte)
 	}
 
-	return string(runes)
+	return string(runes), nil
 }
 
-func (cm *Charmap) EncodeString(str string) []byte {
-	out := make([]byte, 0)
+// EncodeString encodes str, substituting runes that have no encoding
+// according to fb, which may be nil. It panics if a rune can't be encoded or
+// substituted.
+func (cm *Charmap) EncodeString(str string, fb *Fallback) []byte {
+	out, _, err := cm.EncodeFallback(str, fb)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
 
+// Encode is like EncodeString without a fallback, but returns an *EncodeError
+// instead of panicking when a rune has no encoding.
+func (cm *Charmap) Encode(str string) ([]byte, error) {
+	out := make([]byte, 0, len(str))
+
+	pos := 0
 	for _, c := range str {
-		if c >= 0xFF80 {
-			panic("what even IS this?")
+		b, ok := cm.encodeRune(c)
+		if !ok {
+			return nil, &EncodeError{Rune: c, Pos: pos}
 		}
+		out = append(out, b...)
+		pos++
+	}
 
-		savedChar := c
+	return out, nil
+}
 
-		if c >= 0x80 {
-			curIndex := int32(128)
-			maxIndex := cm.NumEntries
+// index returns the first table index of a non-ASCII rune that can be
+// reached with the jump entries.
+func (cm *Charmap) index(c rune) (int32, bool) {
+	used := int32(cm.usedEntries())
 
-			if cm.NumEntries > 128 {
-				for curIndex < maxIndex {
-					if rune(cm.EntryTable[curIndex]) == c {
-						break
-					}
-					curIndex++
-				}
-			}
+	for curIndex := int32(128); curIndex < used; curIndex++ {
+		if rune(cm.EntryTable[curIndex]) != c {
+			continue
+		}
+		if curIndex < 256 {
+			return curIndex, true
+		}
+		if _, ok := cm.jump(curIndex >> 7); ok {
+			return curIndex, true
+		}
+	}
 
-			if curIndex >= 256 {
-				if curIndex != maxIndex {
-					c = 128
-					searchIndex := 128
-					update := true
-
-					for rune(cm.EntryTable[searchIndex]) != curIndex>>7 {
-						c++
-						if c >= 256 {
-							update = false
-							break
-						}
-						searchIndex++
-					}
-
-					if update {
-						out = append(out, byte(c))
-						out = append(out, byte(curIndex%128-128))
-					}
-				}
+	return 0, false
+}
 
-				notFound := c == 256 || curIndex == maxIndex
-				c = savedChar
-				if notFound {
-					panic(fmt.Sprintf("could not encode character %c (%d)! string: %s", c, c, str))
-				}
-			} else {
-				out = append(out, byte(curIndex))
-			}
-		} else {
-			out = append(out, byte(c))
+// jump returns the single byte slot of the jump entry for page.
+func (cm *Charmap) jump(page int32) (byte, bool) {
+	used := cm.usedEntries()
+	for lead := 128; lead < 256 && lead < used; lead++ {
+		if int32(cm.EntryTable[lead]) == page {
+			return byte(lead), true
 		}
 	}
+	return 0, false
+}
 
-	return out
+func (cm *Charmap) encodeRune(c rune) ([]byte, bool) {
+	if c < 0x80 {
+		return []byte{byte(c)}, true
+	}
+	if !storable(c) {
+		return nil, false
+	}
+
+	curIndex, ok := cm.index(c)
+	if !ok {
+		return nil, false
+	}
+	if curIndex < 256 {
+		return []byte{byte(curIndex)}, true
+	}
+
+	lead, _ := cm.jump(curIndex >> 7)
+	return []byte{lead, byte(curIndex%128 + 128)}, true
 }
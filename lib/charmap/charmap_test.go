@@ -2,6 +2,7 @@ package charmap_test
 
 import (
 	"github.com/WorldUnitedNFS/worldlangedit/lib"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/charmap"
 	"io/ioutil"
 	"testing"
 )
@@ -24,3 +25,99 @@ func TestDecode(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestEncodeDecodeErrors(t *testing.T) {
+	cm, err := charmap.Build([]rune{'ä'})
+	if err != nil {
+		t.Fatalf("Failed to build charmap: %v", err)
+	}
+
+	_, err = cm.Encode("aäb€")
+	if ee, ok := err.(*charmap.EncodeError); !ok || ee.Rune != '€' || ee.Pos != 3 {
+		t.Errorf("Expected EncodeError for € at position 3, got %v", err)
+	}
+
+	_, err = cm.Decode([]byte{'a', 0xF0})
+	if de, ok := err.(*charmap.DecodeError); !ok || de.Offset != 1 {
+		t.Errorf("Expected DecodeError at offset 1, got %v", err)
+	}
+}
+
+func TestDecodeWithinNumEntries(t *testing.T) {
+	cm, err := charmap.Build([]rune{'ä'})
+	if err != nil {
+		t.Fatalf("Failed to build charmap: %v", err)
+	}
+	// a rune past NumEntries is neither decoded nor encoded
+	cm.EntryTable[0x81] = 2
+	cm.EntryTable[0x82] = 'ö'
+	cm.EntryTable[0x100] = 'ü'
+
+	for _, b := range [][]byte{{0x82}, {0x81, 0x80}, {0x81}} {
+		if s, err := cm.Decode(b); err == nil {
+			t.Errorf("Decoded % x to %q past NumEntries", b, s)
+		}
+	}
+	if _, err := cm.Encode("ö"); err == nil {
+		t.Errorf("Encoded a rune past NumEntries")
+	}
+
+	// every string Decode accepts encodes back to the same bytes
+	cm.NumEntries = 0x101
+	for _, b := range [][]byte{{0x80}, {0x82}, {0x81, 0x80}, {'a', 0x81, 0x80, 0x80}} {
+		s, err := cm.Decode(b)
+		if err != nil {
+			t.Errorf("Failed to decode % x: %v", b, err)
+			continue
+		}
+		if out, err := cm.Encode(s); err != nil || string(out) != string(b) {
+			t.Errorf("Encoding %q gave % x (%v), want % x", s, out, err, b)
+		}
+	}
+	if _, err := cm.Decode([]byte{0x81, 'a'}); err == nil {
+		t.Errorf("Decoded a jump entry followed by an ASCII byte")
+	}
+}
+
+func TestEncodeFallback(t *testing.T) {
+	cm, err := charmap.Build([]rune{'ä'})
+	if err != nil {
+		t.Fatalf("Failed to build charmap: %v", err)
+	}
+
+	str := "“ä”…€"
+
+	_, _, err = cm.EncodeFallback(str, &charmap.Fallback{Policy: charmap.FallbackError})
+	if _, ok := err.(*charmap.EncodeError); !ok {
+		t.Errorf("Expected EncodeError, got %v", err)
+	}
+
+	b, subs, err := cm.EncodeFallback(str, &charmap.Fallback{Policy: charmap.FallbackReplace})
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if s := cm.DecodeBytes(b); s != "?ä???" || len(subs) != 4 {
+		t.Errorf("Expected 4 substitutions and ?ä???, got %d and %s", len(subs), s)
+	}
+
+	b, subs, err = cm.EncodeFallback(str, &charmap.Fallback{Policy: charmap.FallbackTransliterate})
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if s := cm.DecodeBytes(b); s != "\"ä\"...?" {
+		t.Errorf("Expected \"ä\"...?, got %s", s)
+	}
+	if len(subs) != 4 || subs[2].Rune != '…' || subs[2].Pos != 3 || subs[3].Replacement != "?" {
+		t.Errorf("Unexpected substitutions: %v", subs)
+	}
+
+	if s := cm.DecodeBytes(cm.EncodeString(str, &charmap.Fallback{Policy: charmap.FallbackTransliterate})); s != "\"ä\"...?" {
+		t.Errorf("Expected EncodeString to transliterate, got %s", s)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected EncodeString without a fallback to panic")
+		}
+	}()
+	cm.EncodeString(str, nil)
+}
@@ -0,0 +1,142 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package charmap
+
+import "strings"
+
+type FallbackPolicy int
+
+const (
+	// FallbackError fails on the first rune that can't be encoded.
+	FallbackError FallbackPolicy = iota
+	// FallbackReplace replaces runes that can't be encoded with the
+	// replacement string.
+	FallbackReplace
+	// FallbackTransliterate replaces runes that can't be encoded using the
+	// transliteration table, and with the replacement string if the table has
+	// no usable entry.
+	FallbackTransliterate
+)
+
+// DefaultTransliterations maps common typographic characters to ASCII.
+var DefaultTransliterations = map[rune]string{
+	'\u00A0': " ",   // no-break space
+	'\u2007': " ",   // figure space
+	'\u2009': " ",   // thin space
+	'\u202F': " ",   // narrow no-break space
+	'\u2010': "-",   // hyphen
+	'\u2011': "-",   // non-breaking hyphen
+	'\u2012': "-",   // figure dash
+	'\u2013': "-",   // en dash
+	'\u2014': "-",   // em dash
+	'\u2018': "'",   // left single quotation mark
+	'\u2019': "'",   // right single quotation mark
+	'\u201A': ",",   // single low-9 quotation mark
+	'\u201B': "'",   // single high-reversed-9 quotation mark
+	'\u201C': "\"",  // left double quotation mark
+	'\u201D': "\"",  // right double quotation mark
+	'\u201E': "\"",  // double low-9 quotation mark
+	'\u00AB': "\"",  // left-pointing double angle quotation mark
+	'\u00BB': "\"",  // right-pointing double angle quotation mark
+	'\u2039': "'",   // single left-pointing angle quotation mark
+	'\u203A': "'",   // single right-pointing angle quotation mark
+	'\u2026': "...", // horizontal ellipsis
+	'\u2022': "*",   // bullet
+	'\u00B7': ".",   // middle dot
+	'\u2122': "TM",  // trade mark sign
+	'\u00A9': "(C)", // copyright sign
+	'\u00AE': "(R)", // registered sign
+	'\u00D7': "x",   // multiplication sign
+	'\u200B': "",    // zero width space
+	'\uFEFF': "",    // zero width no-break space
+}
+
+type Fallback struct {
+	Policy FallbackPolicy
+	// Table is used by FallbackTransliterate. DefaultTransliterations is used
+	// if it is nil.
+	Table map[rune]string
+	// Replacement defaults to "?".
+	Replacement string
+}
+
+// Substitution records a rune replaced by a fallback. Pos is the index of the
+// rune in the original string.
+type Substitution struct {
+	Rune        rune
+	Pos         int
+	Replacement string
+}
+
+// CanEncode reports whether every rune of s has an encoding.
+func (cm *Charmap) CanEncode(s string) bool {
+	for _, c := range s {
+		if _, ok := cm.encodeRune(c); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Substitute returns str with every rune that cm can't encode replaced
+// according to fb, along with the substitutions it made. With FallbackError,
+// or if a replacement can't be encoded either, it returns an *EncodeError.
+func (cm *Charmap) Substitute(str string, fb *Fallback) (string, []Substitution, error) {
+	var sb strings.Builder
+	subs := make([]Substitution, 0)
+
+	replacement := "?"
+	table := DefaultTransliterations
+	if fb != nil {
+		if fb.Replacement != "" {
+			replacement = fb.Replacement
+		}
+		if fb.Table != nil {
+			table = fb.Table
+		}
+	}
+
+	pos := 0
+	for _, c := range str {
+		if _, ok := cm.encodeRune(c); ok {
+			sb.WriteRune(c)
+			pos++
+			continue
+		}
+		if fb == nil || fb.Policy == FallbackError {
+			return "", nil, &EncodeError{Rune: c, Pos: pos}
+		}
+
+		r := replacement
+		if t, exists := table[c]; exists && fb.Policy == FallbackTransliterate && cm.CanEncode(t) {
+			r = t
+		}
+		if !cm.CanEncode(r) {
+			return "", nil, &EncodeError{Rune: c, Pos: pos}
+		}
+
+		sb.WriteString(r)
+		subs = append(subs, Substitution{Rune: c, Pos: pos, Replacement: r})
+		pos++
+	}
+
+	return sb.String(), subs, nil
+}
+
+// EncodeFallback is like Encode, but substitutes runes that can't be encoded
+// according to fb. A nil fb or FallbackError encodes like Encode.
+func (cm *Charmap) EncodeFallback(str string, fb *Fallback) ([]byte, []Substitution, error) {
+	if fb == nil || fb.Policy == FallbackError {
+		b, err := cm.Encode(str)
+		return b, nil, err
+	}
+
+	s, subs, err := cm.Substitute(str, fb)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := cm.Encode(s)
+	return b, subs, err
+}
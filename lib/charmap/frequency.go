@@ -0,0 +1,69 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package charmap
+
+import "sort"
+
+// Frequencies counts how often every rune that needs a charmap entry is used
+// in strs.
+func Frequencies(strs []string) map[rune]int {
+	freq := make(map[rune]int)
+	for _, s := range strs {
+		for _, c := range s {
+			if storable(c) {
+				freq[c]++
+			}
+		}
+	}
+	return freq
+}
+
+// RunesByFrequency returns the same runes as Runes, but with the runes that
+// are not pinned ordered from the most to the least frequently used in strs,
+// so the most common runes get the single byte slots. Pinned runes used in
+// strs come first in their given order, so they keep their slots; pinned runes
+// that are not used at all come last.
+func RunesByFrequency(pinned []rune, strs []string) []rune {
+	freq := Frequencies(strs)
+	chars := Runes(pinned, strs)
+
+	isPinned := make(map[rune]bool, len(pinned))
+	for _, c := range pinned {
+		isPinned[c] = true
+	}
+
+	used := make([]rune, 0, len(chars))
+	var rest, unused []rune
+	for _, c := range chars {
+		switch {
+		case freq[c] == 0:
+			unused = append(unused, c)
+		case isPinned[c]:
+			used = append(used, c)
+		default:
+			rest = append(rest, c)
+		}
+	}
+
+	sort.SliceStable(rest, func(i, j int) bool {
+		fi, fj := freq[rest[i]], freq[rest[j]]
+		if fi != fj {
+			return fi > fj
+		}
+		return rest[i] < rest[j]
+	})
+
+	return append(append(used, rest...), unused...)
+}
+
+// EncodedSize returns the number of bytes strs take up when encoded with cm,
+// not counting terminators.
+func (cm *Charmap) EncodedSize(strs []string) int {
+	size := 0
+	for _, s := range strs {
+		size += len(cm.EncodeString(s, nil))
+	}
+	return size
+}
@@ -0,0 +1,91 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package charmap
+
+// JumpEntry is a single byte slot that selects a page of 128 table entries
+// for the byte following it.
+type JumpEntry struct {
+	Index int
+	Page  int
+}
+
+// RuneEntry is a rune stored in the table along with the bytes it encodes to.
+// Bytes is nil if no jump entry leads to the rune's page.
+type RuneEntry struct {
+	Rune  rune
+	Index int
+	Bytes []byte
+}
+
+// Layout describes how the entries of a charmap are used.
+type Layout struct {
+	NumEntries int
+	Jumps      []JumpEntry
+	SingleByte []RuneEntry
+	TwoByte    []RuneEntry
+	// Free is the number of table entries after NumEntries.
+	Free int
+}
+
+// usedEntries returns NumEntries clamped to the table size.
+func (cm *Charmap) usedEntries() int {
+	n := int(cm.NumEntries)
+	if n > TableSize {
+		n = TableSize
+	}
+	if n < 0 {
+		n = 0
+	}
+	return n
+}
+
+// JumpEntries returns the jump entries in table order.
+func (cm *Charmap) JumpEntries() []JumpEntry {
+	jumps := make([]JumpEntry, 0)
+	for i := 0x80; i < 0x100 && i < cm.usedEntries(); i++ {
+		if v := cm.EntryTable[i]; v != 0 && v < 0x80 {
+			jumps = append(jumps, JumpEntry{Index: i, Page: int(v)})
+		}
+	}
+	return jumps
+}
+
+// Entries returns every rune in the table in table order.
+func (cm *Charmap) Entries() []RuneEntry {
+	entries := make([]RuneEntry, 0)
+	for i := 0x80; i < cm.usedEntries(); i++ {
+		c := rune(cm.EntryTable[i])
+		if c < 0x80 {
+			continue
+		}
+		b, _ := cm.encodeRune(c)
+		entries = append(entries, RuneEntry{
+			Rune:  c,
+			Index: i,
+			Bytes: b,
+		})
+	}
+	return entries
+}
+
+func (cm *Charmap) Inspect() *Layout {
+	l := &Layout{
+		NumEntries: int(cm.NumEntries),
+		Jumps:      cm.JumpEntries(),
+		SingleByte: make([]RuneEntry, 0),
+		TwoByte:    make([]RuneEntry, 0),
+		Free:       TableSize - cm.usedEntries(),
+	}
+
+	for _, e := range cm.Entries() {
+		if e.Index < 0x100 {
+			l.SingleByte = append(l.SingleByte, e)
+		} else {
+			l.TwoByte = append(l.TwoByte, e)
+		}
+	}
+
+	return l
+}
@@ -0,0 +1,30 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package charmap
+
+// Merge lays out one charmap shared by several packs. pinned and strs hold the
+// pinned runes and the strings of each pack, the runes are ordered by their
+// frequency across all of them. It returns the rune order along with the
+// charmap so the packs can pin it, or a *TooManyRunesError if the combined
+// set doesn't fit.
+func Merge(pinned [][]rune, strs [][]string) ([]rune, *Charmap, error) {
+	allPinned := make([]rune, 0)
+	for _, p := range pinned {
+		allPinned = append(allPinned, p...)
+	}
+
+	allStrs := make([]string, 0)
+	for _, s := range strs {
+		allStrs = append(allStrs, s...)
+	}
+
+	chars := RunesByFrequency(allPinned, allStrs)
+	cm, err := Build(chars)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return chars, cm, nil
+}
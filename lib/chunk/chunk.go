@@ -0,0 +1,141 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package chunk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// PaddingID is the id of the chunks used to align the following chunk.
+const PaddingID = 0
+
+const (
+	headerSize  = 8
+	maxPrealloc = 1 << 20
+)
+
+type Chunk struct {
+	ID   uint32
+	Data []byte
+}
+
+type Reader struct {
+	r      io.Reader
+	offset int64
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// Offset returns the stream offset of the next chunk header.
+func (r *Reader) Offset() int64 {
+	return r.offset
+}
+
+// Next reads the next chunk. It returns io.EOF at a clean end of stream and
+// io.ErrUnexpectedEOF if the stream ends inside a chunk; in the latter case the
+// returned chunk holds whatever part of the payload could be read.
+func (r *Reader) Next() (*Chunk, error) {
+	var hdr [headerSize]byte
+	n, err := io.ReadFull(r.r, hdr[:])
+	r.offset += int64(n)
+	if err != nil {
+		return nil, err
+	}
+
+	length := int64(binary.LittleEndian.Uint32(hdr[4:8]))
+	// Don't trust the length of a possibly corrupt stream with the allocation.
+	buf := bytes.NewBuffer(make([]byte, 0, minInt64(length, maxPrealloc)))
+	m, err := io.CopyN(buf, r.r, length)
+	r.offset += m
+
+	c := &Chunk{
+		ID:   binary.LittleEndian.Uint32(hdr[0:4]),
+		Data: buf.Bytes(),
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// ReadAll reads chunks until the end of the stream.
+func ReadAll(r io.Reader) ([]Chunk, error) {
+	cr := NewReader(r)
+	chunks := make([]Chunk, 0)
+	for {
+		c, err := cr.Next()
+		if err == io.EOF {
+			return chunks, nil
+		}
+		if err != nil {
+			return chunks, err
+		}
+		chunks = append(chunks, *c)
+	}
+}
+
+type Writer struct {
+	w      io.Writer
+	offset int64
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Offset returns the number of bytes written so far.
+func (w *Writer) Offset() int64 {
+	return w.offset
+}
+
+func (w *Writer) WriteChunk(c Chunk) error {
+	var hdr [headerSize]byte
+	binary.LittleEndian.PutUint32(hdr[0:4], c.ID)
+	binary.LittleEndian.PutUint32(hdr[4:8], uint32(len(c.Data)))
+	if err := w.write(hdr[:]); err != nil {
+		return err
+	}
+	return w.write(c.Data)
+}
+
+// Pad writes a padding chunk so that the next chunk starts on a multiple of
+// align. A padding chunk is always written, even if the offset is already
+// aligned, matching the files shipped with the game.
+func (w *Writer) Pad(align int) error {
+	return w.WriteChunk(Chunk{
+		ID:   PaddingID,
+		Data: make([]byte, PaddingLen(w.offset, align)),
+	})
+}
+
+// PaddingLen returns the payload length of the padding chunk Pad would write
+// at the given offset.
+func PaddingLen(offset int64, align int) int {
+	n := align - int(offset%int64(align))
+	for n < headerSize {
+		n += align
+	}
+	return n - headerSize
+}
+
+func (w *Writer) write(b []byte) error {
+	n, err := w.w.Write(b)
+	w.offset += int64(n)
+	return err
+}
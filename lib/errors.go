@@ -0,0 +1,35 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package lib
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrTruncatedHeader    = errors.New("truncated header")
+	ErrBadChunkID         = errors.New("bad chunk id")
+	ErrStringOutOfRange   = errors.New("string offset out of range")
+	ErrMissingCharMap     = errors.New("missing charmap chunk")
+	ErrUnterminatedString = errors.New("unterminated string")
+	ErrTruncatedChunk     = errors.New("truncated chunk")
+	ErrBadTableOffset     = errors.New("unexpected hash table offset")
+	ErrNameTooLong        = errors.New("pack name is longer than 15 bytes")
+)
+
+// ParseError records the byte offset (in the decoded file) at which parsing failed.
+type ParseError struct {
+	Offset int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%v at offset 0x%x", e.Err, e.Offset)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
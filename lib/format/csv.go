@@ -0,0 +1,151 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package format
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CSV is the codec of comma separated files holding one language pack, with a
+// Label, String and Notes column. The notes of a label are a note per line of
+// their cell. The other Pack fields are "# Name: value" lines before the
+// header row. This is not the layout of the project.WriteCsv spreadsheet,
+// which has a column per language and no Pack fields.
+var CSV Codec = csvCodec{}
+
+func init() {
+	Register(CSV)
+}
+
+// encoding/csv drops the carriage return of \r\n inside fields, packs whose
+// line breaks are all \r\n get a Line-Endings: CRLF line to restore them.
+const (
+	propLineEndings = "Line-Endings"
+	crlf            = "CRLF"
+)
+
+var csvHeader = []string{"Label", "String", "Notes"}
+
+type csvCodec struct{}
+
+func (csvCodec) Name() string {
+	return "csv"
+}
+
+func (csvCodec) Extensions() []string {
+	return []string{".csv"}
+}
+
+// lineEndings reports whether the line breaks of p are \r\n. It fails for
+// packs that mix them with bare \n, which encoding/csv can't keep apart.
+func lineEndings(p *Pack) (bool, error) {
+	var bare, windows string
+	for _, l := range p.Labels() {
+		s := p.Entries[l]
+		n := strings.Count(s, "\r\n")
+		if n > 0 && windows == "" {
+			windows = l
+		}
+		if strings.Count(s, "\n") > n && bare == "" {
+			bare = l
+		}
+	}
+	if bare != "" && windows != "" {
+		return false, fmt.Errorf("string %s has \\n line breaks and string %s \\r\\n ones, CSV can't hold both", bare, windows)
+	}
+	return windows != "", nil
+}
+
+func (csvCodec) ReadPack(r io.Reader) (*Pack, error) {
+	br := bufio.NewReader(r)
+	p := NewPack()
+	windows := false
+
+	if bom, err := br.Peek(3); err == nil && string(bom) == "\uFEFF" {
+		_, _ = br.Discard(3)
+	}
+
+	for {
+		if b, err := br.Peek(1); err != nil || b[0] != '#' {
+			break
+		}
+
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		name, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		if strings.EqualFold(name, propLineEndings) {
+			windows = strings.EqualFold(value, crlf)
+		} else if err := p.setProperty(name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	cr := csv.NewReader(br)
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || strings.Join(records[0], ",") != strings.Join(csvHeader, ",") {
+		return nil, fmt.Errorf("header row must be %s", strings.Join(csvHeader, ","))
+	}
+
+	for i, row := range records[1:] {
+		if _, exists := p.Entries[row[0]]; exists {
+			return nil, fmt.Errorf("row %d: label %s appears more than once", i+2, row[0])
+		}
+		s := row[1]
+		if windows {
+			s = strings.ReplaceAll(s, "\n", "\r\n")
+		}
+		p.Entries[row[0]] = s
+		if row[2] != "" {
+			p.SetNotes(row[0], strings.Split(row[2], "\n"))
+		}
+	}
+	return p, nil
+}
+
+func (csvCodec) WritePack(w io.Writer, p *Pack) error {
+	props, err := p.properties()
+	if err != nil {
+		return err
+	}
+	windows, err := lineEndings(p)
+	if err != nil {
+		return err
+	}
+	if windows {
+		props = append(props, property{propLineEndings, crlf})
+	}
+
+	for _, prop := range props {
+		if _, err := fmt.Fprintf(w, "# %s: %s\n", prop.name, prop.value); err != nil {
+			return err
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, l := range p.Labels() {
+		if err := cw.Write([]string{l, p.Entries[l], strings.Join(noteLines(p.Notes[l]), "\n")}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,251 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package format holds the text forms of language packs and the codecs that
+// read and write them.
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Pack is the text form of a language pack: its strings by label, the
+// characters to pin in its charmap and the translator notes by label. Notes
+// are not part of the binary form. Name is the name field of the binary form
+// when it differs from the name of the pack family. OrderedCharMap keeps the
+// charmap in the SpecialChars order whatever layout the pack is built with,
+// packs that share a charmap set it.
+type Pack struct {
+	Name           string `json:",omitempty"`
+	Entries        map[string]string
+	SpecialChars   []string
+	OrderedCharMap bool                `json:",omitempty"`
+	Notes          map[string][]string `json:",omitempty"`
+}
+
+func NewPack() *Pack {
+	return &Pack{
+		Entries:      make(map[string]string),
+		SpecialChars: make([]string, 0),
+	}
+}
+
+// Labels returns the labels of p in ascending order.
+func (p *Pack) Labels() []string {
+	labels := make([]string, 0, len(p.Entries))
+	for l := range p.Entries {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+func (p *Pack) Strings() []string {
+	strs := make([]string, 0, len(p.Entries))
+	for _, e := range p.Entries {
+		strs = append(strs, e)
+	}
+	return strs
+}
+
+// SpecialCharRunes returns the first rune of every SpecialChars entry.
+func (p *Pack) SpecialCharRunes() []rune {
+	specialChars := make([]rune, 0)
+
+	for _, e := range p.SpecialChars {
+		var first rune
+		for _, c := range e {
+			first = c
+			break
+		}
+
+		specialChars = append(specialChars, first)
+	}
+
+	return specialChars
+}
+
+func (p *Pack) AddString(label string, value string) error {
+	if _, exists := p.Entries[label]; exists {
+		return fmt.Errorf("string %s already exists in language pack", label)
+	}
+
+	p.Entries[label] = value
+	return nil
+}
+
+func (p *Pack) RemoveString(label string) error {
+	if _, exists := p.Entries[label]; !exists {
+		return fmt.Errorf("string %s does not exist in language pack", label)
+	}
+	delete(p.Entries, label)
+	delete(p.Notes, label)
+	return nil
+}
+
+// SetNotes replaces the notes on label, no notes remove them. It reports
+// whether the notes changed.
+func (p *Pack) SetNotes(label string, notes []string) bool {
+	old := p.Notes[label]
+	if len(old) == len(notes) {
+		same := true
+		for i := range old {
+			same = same && old[i] == notes[i]
+		}
+		if same {
+			return false
+		}
+	}
+
+	if len(notes) == 0 {
+		delete(p.Notes, label)
+		return true
+	}
+	if p.Notes == nil {
+		p.Notes = make(map[string][]string)
+	}
+	p.Notes[label] = notes
+	return true
+}
+
+// Names of the Pack fields besides the strings and notes, for codecs that
+// store them as name/value pairs.
+const (
+	propName           = "Name"
+	propSpecialChars   = "Special-Chars"
+	propOrderedCharMap = "Ordered-Char-Map"
+)
+
+type property struct {
+	name  string
+	value string
+}
+
+// properties returns the Pack fields besides the strings and notes that are
+// set. SpecialChars is a JSON array.
+func (p *Pack) properties() ([]property, error) {
+	props := make([]property, 0, 3)
+	if p.Name != "" {
+		props = append(props, property{propName, p.Name})
+	}
+	if len(p.SpecialChars) > 0 {
+		chars, err := json.Marshal(p.SpecialChars)
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, property{propSpecialChars, string(chars)})
+	}
+	if p.OrderedCharMap {
+		props = append(props, property{propOrderedCharMap, "yes"})
+	}
+	return props, nil
+}
+
+// setProperty sets a field returned by properties. Unknown names are ignored.
+func (p *Pack) setProperty(name string, value string) error {
+	switch {
+	case strings.EqualFold(name, propName):
+		p.Name = value
+	case strings.EqualFold(name, propSpecialChars):
+		if err := json.Unmarshal([]byte(value), &p.SpecialChars); err != nil {
+			return fmt.Errorf("bad %s %q: %v", propSpecialChars, value, err)
+		}
+	case strings.EqualFold(name, propOrderedCharMap):
+		p.OrderedCharMap = value == "yes"
+	}
+	return nil
+}
+
+// Project is the text form of a pack family: a pack per language, including
+// the Labels pack.
+type Project struct {
+	Name      string
+	Languages map[string]*Pack
+}
+
+// LabelsLanguage is the language of the pack that names the strings of a
+// family. A family without it is not a project.
+const LabelsLanguage = "Labels"
+
+// Codec reads and writes single language packs.
+type Codec interface {
+	// Name is the name the codec is registered and selected under.
+	Name() string
+	// Extensions lists the file extensions of the format, the first one is
+	// used for new files.
+	Extensions() []string
+	ReadPack(r io.Reader) (*Pack, error)
+	WritePack(w io.Writer, p *Pack) error
+}
+
+// ProjectCodec is implemented by codecs that store a whole project in one
+// file.
+type ProjectCodec interface {
+	Codec
+	ReadProject(r io.Reader) (*Project, error)
+	WriteProject(w io.Writer, p *Project) error
+}
+
+var registry = struct {
+	sync.RWMutex
+	byName map[string]Codec
+	byExt  map[string]Codec
+}{
+	byName: make(map[string]Codec),
+	byExt:  make(map[string]Codec),
+}
+
+// Register makes a codec available by its name and extensions. It panics if
+// the name is already taken. Extensions already claimed by another codec stay
+// with the first one.
+func Register(c Codec) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	name := strings.ToLower(c.Name())
+	if _, exists := registry.byName[name]; exists {
+		panic(fmt.Sprintf("format: codec %s registered twice", name))
+	}
+	registry.byName[name] = c
+
+	for _, ext := range c.Extensions() {
+		ext = strings.ToLower(ext)
+		if _, exists := registry.byExt[ext]; !exists {
+			registry.byExt[ext] = c
+		}
+	}
+}
+
+// Lookup returns the codec registered under name.
+func Lookup(name string) (Codec, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	c, ok := registry.byName[strings.ToLower(name)]
+	return c, ok
+}
+
+// ForExtension returns the codec for a file extension, including the dot.
+func ForExtension(ext string) (Codec, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	c, ok := registry.byExt[strings.ToLower(ext)]
+	return c, ok
+}
+
+// Names returns the names of all registered codecs in ascending order.
+func Names() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	names := make([]string, 0, len(registry.byName))
+	for name := range registry.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,307 @@
+package format_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/WorldUnitedNFS/worldlangedit/lib/format"
+)
+
+type testCodec struct{}
+
+func (testCodec) Name() string                             { return "Test" }
+func (testCodec) Extensions() []string                     { return []string{".test", ".JSON"} }
+func (testCodec) ReadPack(io.Reader) (*format.Pack, error) { return format.NewPack(), nil }
+func (testCodec) WritePack(io.Writer, *format.Pack) error  { return nil }
+
+// testProjectCodec stores a whole project in one JSON file.
+type testProjectCodec struct{ testCodec }
+
+func (testProjectCodec) Extensions() []string { return []string{".proj"} }
+
+func (testProjectCodec) ReadProject(r io.Reader) (*format.Project, error) {
+	p := &format.Project{}
+	err := json.NewDecoder(r).Decode(p)
+	return p, err
+}
+
+func (testProjectCodec) WriteProject(w io.Writer, p *format.Project) error {
+	return json.NewEncoder(w).Encode(p)
+}
+
+func TestRegistry(t *testing.T) {
+	format.Register(testCodec{})
+
+	if c, ok := format.Lookup("test"); !ok || c.Name() != "Test" {
+		t.Errorf("Codec not found by name")
+	}
+	if c, ok := format.ForExtension(".TEST"); !ok || c.Name() != "Test" {
+		t.Errorf("Codec not found by extension")
+	}
+	if c, ok := format.ForExtension(".json"); !ok || c != format.JSON {
+		t.Errorf("Extension of the JSON codec was taken over")
+	}
+	if names := format.Names(); !reflect.DeepEqual(names, []string{"csv", "json", "po", "test", "xliff"}) {
+		t.Errorf("Unexpected codec names: %v", names)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Registering a codec twice did not panic")
+		}
+	}()
+	format.Register(testCodec{})
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	p := &format.Pack{
+		Name:           "Career",
+		Entries:        map[string]string{"TXT_HELLO": "<Hallo & Tschüss>"},
+		SpecialChars:   []string{"ü"},
+		OrderedCharMap: true,
+		Notes:          map[string][]string{"TXT_HELLO": {"informal"}},
+	}
+
+	var buf bytes.Buffer
+	if err := format.JSON.WritePack(&buf, p); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("<Hallo & Tschüss>")) {
+		t.Errorf("String was escaped: %s", buf.String())
+	}
+
+	read, err := format.JSON.ReadPack(&buf)
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if !reflect.DeepEqual(p, read) {
+		t.Errorf("Pack differs after round trip: %v", read)
+	}
+
+	_, err = format.JSON.ReadPack(bytes.NewBufferString(`{"Entries": {}, "Comment": "x"}`))
+	if err == nil {
+		t.Errorf("Unknown field was accepted")
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	p := &format.Pack{
+		Name: "Career",
+		Entries: map[string]string{
+			"TXT_HELLO": "<Hallo & \"Tsch\u00FCss\">, Welt",
+			"TXT_LINES": "Eins\r\nZwei\tDrei",
+			"TXT_EMPTY": "",
+		},
+		SpecialChars:   []string{"\u00FC", "\""},
+		OrderedCharMap: true,
+		Notes:          map[string][]string{"TXT_HELLO": {"informal", "keep the quotes"}},
+	}
+
+	for _, c := range []format.Codec{format.PO, format.XLIFF, format.CSV} {
+		var buf bytes.Buffer
+		if err := c.WritePack(&buf, p); err != nil {
+			t.Fatalf("%s: failed to write: %v", c.Name(), err)
+		}
+		read, err := c.ReadPack(&buf)
+		if err != nil {
+			t.Fatalf("%s: failed to read: %v", c.Name(), err)
+		}
+		if !reflect.DeepEqual(p, read) {
+			t.Errorf("%s: pack differs after round trip: %+v", c.Name(), read)
+		}
+	}
+
+	plain := format.NewPack()
+	plain.Entries["TXT_HELLO"] = "Hallo"
+	for _, c := range []format.Codec{format.PO, format.XLIFF, format.CSV} {
+		var buf bytes.Buffer
+		if err := c.WritePack(&buf, plain); err != nil {
+			t.Fatalf("%s: failed to write: %v", c.Name(), err)
+		}
+		read, err := c.ReadPack(&buf)
+		if err != nil {
+			t.Fatalf("%s: failed to read: %v", c.Name(), err)
+		}
+		if !reflect.DeepEqual(plain, read) {
+			t.Errorf("%s: pack differs after round trip: %+v", c.Name(), read)
+		}
+	}
+}
+
+func TestCSVLineEndings(t *testing.T) {
+	p := format.NewPack()
+	p.Entries["TXT_A"] = "a\r\nb"
+	p.Entries["TXT_B"] = "a\nb"
+	if err := format.CSV.WritePack(ioutil.Discard, p); err == nil {
+		t.Error("writing mixed line breaks succeeded")
+	}
+
+	delete(p.Entries, "TXT_A")
+	var buf bytes.Buffer
+	if err := format.CSV.WritePack(&buf, p); err != nil {
+		t.Fatal(err)
+	}
+	read, err := format.CSV.ReadPack(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Entries["TXT_B"] != "a\nb" {
+		t.Errorf("Unexpected string: %q", read.Entries["TXT_B"])
+	}
+}
+
+func TestSetNotes(t *testing.T) {
+	p := format.NewPack()
+	p.Entries["TXT_HELLO"] = "Hallo"
+
+	if p.SetNotes("TXT_HELLO", nil) {
+		t.Errorf("Removing missing notes reported a change")
+	}
+	if !p.SetNotes("TXT_HELLO", []string{"informal"}) || p.SetNotes("TXT_HELLO", []string{"informal"}) {
+		t.Errorf("Unexpected change reports when setting notes")
+	}
+	if err := p.RemoveString("TXT_HELLO"); err != nil || len(p.Notes) != 0 {
+		t.Errorf("Notes were kept after removing the string: %v", p.Notes)
+	}
+}
+
+func TestProjectRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "format")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := &format.Project{
+		Name: "Global",
+		Languages: map[string]*format.Pack{
+			"Labels":  {Entries: map[string]string{"TXT_HELLO": "TXT_HELLO"}, SpecialChars: []string{}},
+			"English": {Entries: map[string]string{"TXT_HELLO": "Hello"}, SpecialChars: []string{}},
+		},
+	}
+	if err := format.WriteProject(dir, p, format.JSON); err != nil {
+		t.Fatalf("Failed to write project: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "English_Global.json")); err != nil {
+		t.Errorf("Language file not written: %v", err)
+	}
+	_ = ioutil.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "crack_state.json"), []byte("{}"), 0644)
+
+	names, err := format.FindProjects(dir, format.JSON)
+	if err != nil || !reflect.DeepEqual(names, []string{"Global"}) {
+		t.Errorf("Unexpected projects: %v, %v", names, err)
+	}
+
+	read, err := format.ReadProject(dir, "Global", format.JSON)
+	if err != nil {
+		t.Fatalf("Failed to read project: %v", err)
+	}
+	if !reflect.DeepEqual(p, read) {
+		t.Errorf("Project differs after round trip: %v", read)
+	}
+}
+
+func TestWriteLanguages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "format")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := &format.Project{
+		Name: "Global",
+		Languages: map[string]*format.Pack{
+			"Labels":  {Entries: map[string]string{"TXT_HELLO": "TXT_HELLO"}, SpecialChars: []string{}},
+			"English": {Entries: map[string]string{"TXT_HELLO": "Hello"}, SpecialChars: []string{}},
+			"German":  {Entries: map[string]string{"TXT_HELLO": "Hallo"}, SpecialChars: []string{}},
+		},
+	}
+	if err := format.WriteLanguages(dir, p, format.JSON, []string{"German"}); err != nil {
+		t.Fatalf("Failed to write German: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "German_Global.json")); err != nil {
+		t.Errorf("German file not written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "English_Global.json")); !os.IsNotExist(err) {
+		t.Errorf("English file written: %v", err)
+	}
+	if err := format.WriteLanguages(dir, p, format.JSON, []string{"French"}); err == nil {
+		t.Error("writing a missing language succeeded")
+	}
+}
+
+func TestProjectCodec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "format")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var c format.Codec = testProjectCodec{}
+	p := &format.Project{
+		Name: "Global",
+		Languages: map[string]*format.Pack{
+			"Labels":  {Entries: map[string]string{"TXT_HELLO": "TXT_HELLO"}, SpecialChars: []string{}},
+			"English": {Entries: map[string]string{"TXT_HELLO": "Hello"}, SpecialChars: []string{}},
+		},
+	}
+	if err := format.WriteProject(dir, p, c); err != nil {
+		t.Fatalf("Failed to write project: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Global.proj")); err != nil {
+		t.Errorf("Project file not written: %v", err)
+	}
+
+	names, err := format.FindProjects(dir, c)
+	if err != nil || !reflect.DeepEqual(names, []string{"Global"}) {
+		t.Errorf("Unexpected projects: %v, %v", names, err)
+	}
+
+	p.Languages["English"].Entries["TXT_HELLO"] = "Hello again"
+	if err := format.WriteLanguages(dir, p, c, []string{"English"}); err != nil {
+		t.Fatalf("Failed to write English: %v", err)
+	}
+	read, err := format.ReadProject(dir, "Global", c)
+	if err != nil {
+		t.Fatalf("Failed to read project: %v", err)
+	}
+	if !reflect.DeepEqual(p, read) {
+		t.Errorf("Project differs after round trip: %v", read)
+	}
+}
+
+func TestPackFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "format")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := format.NewPack()
+	p.Entries["TXT_HELLO"] = "Hallo"
+
+	for _, name := range []string{"German_Global.json", "German_Global.po", "German_Global.xlf", "German_Global.csv"} {
+		fp := filepath.Join(dir, name)
+		if err := format.WritePackFile(fp, p); err != nil {
+			t.Fatalf("%s: failed to write: %v", name, err)
+		}
+		read, err := format.ReadPackFile(fp)
+		if err != nil {
+			t.Fatalf("%s: failed to read: %v", name, err)
+		}
+		if !reflect.DeepEqual(p, read) {
+			t.Errorf("%s: pack differs after round trip: %+v", name, read)
+		}
+	}
+
+	if err := format.WritePackFile(filepath.Join(dir, "German_Global.txt"), p); err == nil {
+		t.Error("writing a file without a format succeeded")
+	}
+}
@@ -0,0 +1,47 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package format
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JSON is the codec of the jsontool JSON files.
+var JSON Codec = jsonCodec{}
+
+func init() {
+	Register(JSON)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) Extensions() []string {
+	return []string{".json"}
+}
+
+func (jsonCodec) ReadPack(r io.Reader) (*Pack, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	p := &Pack{}
+	if err := decoder.Decode(p); err != nil {
+		return nil, err
+	}
+	if p.Entries == nil {
+		p.Entries = make(map[string]string)
+	}
+	return p, nil
+}
+
+func (jsonCodec) WritePack(w io.Writer, p *Pack) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", " ")
+	return encoder.Encode(p)
+}
@@ -0,0 +1,102 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package format
+
+import (
+	"fmt"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/po"
+	"io"
+	"strings"
+)
+
+// PO is the codec of gettext PO files holding one language pack. The label is
+// both the msgctxt and the msgid of an entry and the string its msgstr. Notes
+// are translator comments and the other Pack fields X- header fields.
+var PO Codec = poCodec{}
+
+func init() {
+	Register(PO)
+}
+
+type poCodec struct{}
+
+func (poCodec) Name() string {
+	return "po"
+}
+
+func (poCodec) Extensions() []string {
+	return []string{".po"}
+}
+
+// noteLines returns the notes as single lines, a note with line breaks
+// becomes several notes.
+func noteLines(notes []string) []string {
+	lines := make([]string, 0, len(notes))
+	for _, n := range notes {
+		lines = append(lines, strings.Split(n, "\n")...)
+	}
+	return lines
+}
+
+func (poCodec) ReadPack(r io.Reader) (*Pack, error) {
+	f, err := po.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := NewPack()
+	for _, h := range f.Header {
+		if strings.HasPrefix(h.Name, "X-") {
+			if err := p.setProperty(h.Name[2:], h.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, e := range f.Entries {
+		if e.Obsolete {
+			continue
+		}
+		label := e.Context
+		if label == "" {
+			label = e.ID
+		}
+		if _, exists := p.Entries[label]; exists {
+			return nil, fmt.Errorf("label %s appears more than once", label)
+		}
+		p.Entries[label] = e.Str
+		p.SetNotes(label, e.Comments)
+	}
+	return p, nil
+}
+
+func (poCodec) WritePack(w io.Writer, p *Pack) error {
+	props, err := p.properties()
+	if err != nil {
+		return err
+	}
+
+	f := &po.File{
+		Header: []po.HeaderField{
+			{Name: "MIME-Version", Value: "1.0"},
+			{Name: "Content-Type", Value: "text/plain; charset=UTF-8"},
+			{Name: "Content-Transfer-Encoding", Value: "8bit"},
+		},
+		Entries: make([]*po.Entry, 0, len(p.Entries)),
+	}
+	for _, prop := range props {
+		f.Header = append(f.Header, po.HeaderField{Name: "X-" + prop.name, Value: prop.value})
+	}
+
+	for _, l := range p.Labels() {
+		f.Entries = append(f.Entries, &po.Entry{
+			Comments: noteLines(p.Notes[l]),
+			Context:  l,
+			ID:       l,
+			Str:      p.Entries[l],
+		})
+	}
+	return f.Write(w)
+}
@@ -0,0 +1,203 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package format
+
+import (
+	"fmt"
+	"github.com/WorldUnitedNFS/worldlangedit/lib"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// hasExtension reports whether fp has one of the extensions of c.
+func hasExtension(fp string, c Codec) bool {
+	ext := filepath.Ext(fp)
+	for _, e := range c.Extensions() {
+		if strings.EqualFold(ext, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindProjects returns the names of the projects in dir in ascending order. A
+// ProjectCodec stores a project in a <Name><ext> file, other codecs in a
+// <Language>_<Name><ext> file per language. Families without a
+// Labels_<Name><ext> file are left out.
+func FindProjects(dir string, c Codec) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	_, isProject := c.(ProjectCodec)
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for _, fp := range files {
+		if !hasExtension(fp, c) {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(fp), filepath.Ext(fp))
+		if !isProject {
+			var lang string
+			var ok bool
+			if lang, name, ok = lib.SplitFileName(fp); !ok || lang != LabelsLanguage {
+				continue
+			}
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func readPack(fp string, c Codec) (*Pack, error) {
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return c.ReadPack(f)
+}
+
+// ReadProject reads the project called name from dir.
+func ReadProject(dir string, name string, c Codec) (*Project, error) {
+	if pc, ok := c.(ProjectCodec); ok {
+		f, err := os.Open(filepath.Join(dir, name+c.Extensions()[0]))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		p, err := pc.ReadProject(f)
+		if err != nil {
+			return nil, err
+		}
+		p.Name = name
+		return p, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*_"+name+".*"))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Project{Name: name, Languages: make(map[string]*Pack)}
+	for _, fp := range files {
+		lang, pack, ok := lib.SplitFileName(fp)
+		if !ok || pack != name || !hasExtension(fp, c) {
+			continue
+		}
+		lp, err := readPack(fp, c)
+		if err != nil {
+			return nil, &os.PathError{Op: "read", Path: fp, Err: err}
+		}
+		p.Languages[lang] = lp
+	}
+	return p, nil
+}
+
+func writePack(fp string, lp *Pack, c Codec) error {
+	f, err := os.Create(fp)
+	if err != nil {
+		return err
+	}
+	err = c.WritePack(f, lp)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// codecFor returns the codec registered for the extension of fp.
+func codecFor(fp string) (Codec, error) {
+	c, ok := ForExtension(filepath.Ext(fp))
+	if !ok {
+		return nil, fmt.Errorf("no format for %s files, available formats: %s", filepath.Ext(fp), strings.Join(Names(), ", "))
+	}
+	return c, nil
+}
+
+// ReadPackFile reads the pack in fp with the codec registered for its
+// extension.
+func ReadPackFile(fp string) (*Pack, error) {
+	c, err := codecFor(fp)
+	if err != nil {
+		return nil, err
+	}
+	lp, err := readPack(fp, c)
+	if err != nil {
+		return nil, &os.PathError{Op: "read", Path: fp, Err: err}
+	}
+	return lp, nil
+}
+
+// WritePackFile writes lp to fp with the codec registered for its extension.
+func WritePackFile(fp string, lp *Pack) error {
+	c, err := codecFor(fp)
+	if err != nil {
+		return err
+	}
+	return writePack(fp, lp, c)
+}
+
+// WriteProject writes p to dir.
+func WriteProject(dir string, p *Project, c Codec) error {
+	ext := c.Extensions()[0]
+
+	if pc, ok := c.(ProjectCodec); ok {
+		f, err := os.Create(filepath.Join(dir, p.Name+ext))
+		if err != nil {
+			return err
+		}
+		err = pc.WriteProject(f, p)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+
+	for lang, lp := range p.Languages {
+		if err := writePack(filepath.Join(dir, lang+"_"+p.Name+ext), lp, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteLanguages writes the packs of langs of p to dir. A ProjectCodec stores
+// every language in one file, so it writes all of p.
+func WriteLanguages(dir string, p *Project, c Codec, langs []string) error {
+	if _, ok := c.(ProjectCodec); ok {
+		return WriteProject(dir, p, c)
+	}
+
+	ext := c.Extensions()[0]
+	for _, lang := range langs {
+		lp, exists := p.Languages[lang]
+		if !exists {
+			return fmt.Errorf("project %s has no language %s", p.Name, lang)
+		}
+		if err := writePack(filepath.Join(dir, lang+"_"+p.Name+ext), lp, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LanguageNames returns the languages of p in ascending order.
+func (p *Project) LanguageNames() []string {
+	names := make([]string, 0, len(p.Languages))
+	for l := range p.Languages {
+		names = append(names, l)
+	}
+	sort.Strings(names)
+	return names
+}
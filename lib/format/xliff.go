@@ -0,0 +1,79 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package format
+
+import (
+	"fmt"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/xliff"
+	"io"
+)
+
+// XLIFF is the codec of XLIFF files holding one language pack. The label is
+// the id of a unit and the string its target. Notes are unit notes and the
+// other Pack fields attributes of the file element. It writes XLIFF 1.2 and
+// reads 1.2 and 2.0.
+var XLIFF Codec = xliffCodec{}
+
+func init() {
+	Register(XLIFF)
+}
+
+type xliffCodec struct{}
+
+func (xliffCodec) Name() string {
+	return "xliff"
+}
+
+func (xliffCodec) Extensions() []string {
+	return []string{".xlf", ".xliff"}
+}
+
+func (xliffCodec) ReadPack(r io.Reader) (*Pack, error) {
+	doc, err := xliff.Read(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := NewPack()
+	for _, prop := range doc.Properties {
+		if err := p.setProperty(prop.Name, prop.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, u := range doc.Units {
+		if _, exists := p.Entries[u.ID]; exists {
+			return nil, fmt.Errorf("label %s appears more than once", u.ID)
+		}
+		p.Entries[u.ID] = u.Target
+		p.SetNotes(u.ID, u.Notes)
+	}
+	return p, nil
+}
+
+func (xliffCodec) WritePack(w io.Writer, p *Pack) error {
+	props, err := p.properties()
+	if err != nil {
+		return err
+	}
+
+	doc := &xliff.Document{
+		Version:    xliff.Version12,
+		Properties: make([]xliff.Property, 0, len(props)),
+		Units:      make([]xliff.Unit, 0, len(p.Entries)),
+	}
+	for _, prop := range props {
+		doc.Properties = append(doc.Properties, xliff.Property{Name: prop.name, Value: prop.value})
+	}
+
+	for _, l := range p.Labels() {
+		doc.Units = append(doc.Units, xliff.Unit{
+			ID:     l,
+			Target: p.Entries[l],
+			Notes:  p.Notes[l],
+		})
+	}
+	return doc.Write(w)
+}
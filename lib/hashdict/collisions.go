@@ -0,0 +1,65 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package hashdict
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Collision is a hash shared by several different keys.
+type Collision struct {
+	Hash uint32
+	Keys []string
+}
+
+func (c Collision) Error() string {
+	return fmt.Sprintf("%s all hash to %s", strings.Join(c.Keys, ", "), HexKey(c.Hash))
+}
+
+// Collisions returns the hashes shared by more than one of keys, ordered by
+// hash. Keys are hashed like Hash does, so a hex key collides with the label
+// it stands for.
+func Collisions(keys []string) []Collision {
+	byHash := make(map[uint32][]string)
+	for _, k := range keys {
+		h := Hash(k)
+		if !contains(byHash[h], k) {
+			byHash[h] = append(byHash[h], k)
+		}
+	}
+
+	collisions := make([]Collision, 0)
+	for h, ks := range byHash {
+		if len(ks) > 1 {
+			sort.Strings(ks)
+			collisions = append(collisions, Collision{Hash: h, Keys: ks})
+		}
+	}
+	sort.Slice(collisions, func(i, j int) bool { return collisions[i].Hash < collisions[j].Hash })
+
+	return collisions
+}
+
+// Collides returns the key in keys that key collides with.
+func Collides(keys []string, key string) (string, bool) {
+	h := Hash(key)
+	for _, k := range keys {
+		if k != key && Hash(k) == h {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+func contains(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package hashdict
+
+import (
+	"github.com/WorldUnitedNFS/worldlangedit/lib"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Tokens splits labels into the tokens a Cracker recombines: every part up to
+// and including an underscore, and the part after the last one. GM_CAR_DESC
+// gives GM_, CAR_ and DESC.
+func Tokens(labels []string) []string {
+	seen := make(map[string]bool)
+	tokens := make([]string, 0)
+
+	for _, l := range labels {
+		for l != "" {
+			i := strings.IndexByte(l, '_')
+			t := l
+			if i >= 0 {
+				t = l[:i+1]
+			}
+			l = l[len(t):]
+			if !seen[t] {
+				seen[t] = true
+				tokens = append(tokens, t)
+			}
+		}
+	}
+
+	sort.Strings(tokens)
+	return tokens
+}
+
+// Unit is a slice of the search space: every candidate made of Length tokens
+// that starts with token First.
+type Unit struct {
+	Length int
+	First  int
+}
+
+// Cracker searches for labels made of known tokens that hash to one of a set
+// of target hashes.
+type Cracker struct {
+	tokens    []string
+	maxTokens int
+	targets   map[uint32]bool
+	// BinHash(p+t) == BinHash(p)*mul[t] + add[t] for a non-empty p
+	mul []uint32
+	add []uint32
+}
+
+func NewCracker(tokens []string, targets []uint32, maxTokens int) *Cracker {
+	c := &Cracker{
+		tokens:    tokens,
+		maxTokens: maxTokens,
+		targets:   make(map[uint32]bool, len(targets)),
+		mul:       make([]uint32, len(tokens)),
+		add:       make([]uint32, len(tokens)),
+	}
+	for _, h := range targets {
+		c.targets[h] = true
+	}
+	for i, t := range tokens {
+		mul, add := uint32(1), uint32(0)
+		for _, r := range t {
+			mul *= 33
+			add = add*33 + uint32(r)
+		}
+		c.mul[i] = mul
+		c.add[i] = add
+	}
+	return c
+}
+
+// Units returns the whole search space, shortest candidates first.
+func (c *Cracker) Units() []Unit {
+	units := make([]Unit, 0, c.maxTokens*len(c.tokens))
+	for length := 1; length <= c.maxTokens; length++ {
+		for first := range c.tokens {
+			units = append(units, Unit{Length: length, First: first})
+		}
+	}
+	return units
+}
+
+// Run searches units on the given number of goroutines. found is called for
+// every candidate that hashes to a target and done after every finished unit.
+// Calls to found and done are serialized. Closing stop makes Run return once
+// the units in progress are finished, a nil stop never does.
+func (c *Cracker) Run(units []Unit, workers int, stop <-chan struct{}, found func(hash uint32, label string), done func(Unit)) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	work := make(chan Unit)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stack := make([]int, 0, c.maxTokens)
+			for u := range work {
+				stack = append(stack[:0], u.First)
+				c.search(lib.BinHash(c.tokens[u.First]), stack, u.Length, func(hash uint32, label string) {
+					mu.Lock()
+					found(hash, label)
+					mu.Unlock()
+				})
+				mu.Lock()
+				done(u)
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, u := range units {
+		select {
+		case work <- u:
+		case <-stop:
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+}
+
+func (c *Cracker) search(hash uint32, stack []int, length int, found func(uint32, string)) {
+	if len(stack) == length {
+		if c.targets[hash] {
+			found(hash, c.label(stack))
+		}
+		return
+	}
+
+	for i := range c.tokens {
+		c.search(hash*c.mul[i]+c.add[i], append(stack, i), length, found)
+	}
+}
+
+func (c *Cracker) label(stack []int) string {
+	var sb strings.Builder
+	for _, i := range stack {
+		sb.WriteString(c.tokens[i])
+	}
+	return sb.String()
+}
+
+// Candidates holds the labels found for each hash, by hex key.
+type Candidates map[string][]string
+
+// Add adds label as a candidate for hash and reports whether it wasn't one
+// already. A unit that is searched again after an interrupted run finds the
+// same labels again.
+func (c Candidates) Add(hash uint32, label string) bool {
+	key := HexKey(hash)
+	for _, l := range c[key] {
+		if l == label {
+			return false
+		}
+	}
+	c[key] = append(c[key], label)
+	return true
+}
@@ -0,0 +1,141 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package hashdict resolves string hashes back to their labels.
+package hashdict
+
+import (
+	"bufio"
+	"github.com/WorldUnitedNFS/worldlangedit/lib"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dict maps hashes to the labels they were computed from.
+type Dict struct {
+	names map[uint32]string
+}
+
+func New() *Dict {
+	return &Dict{names: make(map[uint32]string)}
+}
+
+// Add adds a label and returns its hash. A label whose hash is already known
+// is ignored, the first one added wins.
+func (d *Dict) Add(label string) uint32 {
+	hash := lib.BinHash(label)
+	if _, exists := d.names[hash]; !exists {
+		d.names[hash] = label
+	}
+	return hash
+}
+
+// AddLangFile adds the strings of a labels file.
+func (d *Dict) AddLangFile(lFile *lib.LangFile) {
+	lFile.Range(func(e *lib.LangFileEntry) bool {
+		if _, exists := d.names[e.Hash]; !exists {
+			d.names[e.Hash] = e.String
+		}
+		return true
+	})
+}
+
+// ReadLabels reads a label list from r, one label per line. Blank lines and
+// lines starting with # are skipped.
+func ReadLabels(r io.Reader) ([]string, error) {
+	labels := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		labels = append(labels, line)
+	}
+	return labels, scanner.Err()
+}
+
+// LoadLabels reads a label list file.
+func LoadLabels(fp string) ([]string, error) {
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadLabels(f)
+}
+
+// Load adds the labels of a label list read from r.
+func (d *Dict) Load(r io.Reader) error {
+	labels, err := ReadLabels(r)
+	for _, l := range labels {
+		d.Add(l)
+	}
+	return err
+}
+
+// LoadFile adds the labels of a label list file.
+func (d *Dict) LoadFile(fp string) error {
+	labels, err := LoadLabels(fp)
+	for _, l := range labels {
+		d.Add(l)
+	}
+	return err
+}
+
+func (d *Dict) Lookup(hash uint32) (string, bool) {
+	label, ok := d.names[hash]
+	return label, ok
+}
+
+// Resolve returns the label of hash, or its hex key if it is unknown.
+func (d *Dict) Resolve(hash uint32) string {
+	if label, ok := d.names[hash]; ok {
+		return label
+	}
+	return HexKey(hash)
+}
+
+func (d *Dict) Len() int {
+	return len(d.names)
+}
+
+// HexKey returns the key an unresolved hash is stored under in text files.
+func HexKey(hash uint32) string {
+	return lib.HexKey(hash)
+}
+
+// ParseHexKey parses a key written by HexKey.
+func ParseHexKey(key string) (uint32, bool) {
+	if len(key) != 10 || (key[:2] != "0x" && key[:2] != "0X") {
+		return 0, false
+	}
+	hash, err := strconv.ParseUint(key[2:], 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(hash), true
+}
+
+// Hash returns the hash of a text file key: the value of a hex key, or the
+// hash of a label.
+func Hash(key string) uint32 {
+	if hash, ok := ParseHexKey(key); ok {
+		return hash
+	}
+	return lib.BinHash(key)
+}
+
+// Labels returns every known label in ascending order.
+func (d *Dict) Labels() []string {
+	labels := make([]string, 0, len(d.names))
+	for _, l := range d.names {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+	return labels
+}
@@ -0,0 +1,113 @@
+package hashdict_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/WorldUnitedNFS/worldlangedit/lib"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/hashdict"
+)
+
+func TestResolve(t *testing.T) {
+	d := hashdict.New()
+	err := d.Load(strings.NewReader("# labels\nTXT_HELLO\n\n  GM_CAR_DESC  \n"))
+	if err != nil {
+		t.Fatalf("Failed to load labels: %v", err)
+	}
+
+	if d.Len() != 2 {
+		t.Errorf("Expected 2 labels, got %d", d.Len())
+	}
+	if l := d.Resolve(lib.BinHash("GM_CAR_DESC")); l != "GM_CAR_DESC" {
+		t.Errorf("Expected GM_CAR_DESC, got %s", l)
+	}
+	if l := d.Resolve(0xDEADBEEF); l != "0xDEADBEEF" {
+		t.Errorf("Expected 0xDEADBEEF, got %s", l)
+	}
+}
+
+func TestHash(t *testing.T) {
+	if h := hashdict.Hash("0xDEADBEEF"); h != 0xDEADBEEF {
+		t.Errorf("Expected 0xDEADBEEF, got 0x%08X", h)
+	}
+	if h := hashdict.Hash("TXT_HELLO"); h != lib.BinHash("TXT_HELLO") {
+		t.Errorf("Expected the BinHash of a label, got 0x%08X", h)
+	}
+	for _, key := range []string{"0xDEADBEE", "0xDEADBEEG", "DEADBEEF00"} {
+		if _, ok := hashdict.ParseHexKey(key); ok {
+			t.Errorf("%s parsed as a hex key", key)
+		}
+	}
+}
+
+func TestCrack(t *testing.T) {
+	tokens := hashdict.Tokens([]string{"GM_CAR_DESC", "TXT_HELLO", "TXT_WORLD"})
+	expected := "CAR_ DESC GM_ HELLO TXT_ WORLD"
+	if s := strings.Join(tokens, " "); s != expected {
+		t.Errorf("Expected tokens %s, got %s", expected, s)
+	}
+
+	targets := []uint32{lib.BinHash("TXT_CAR_DESC"), lib.BinHash("GM_WORLD"), 0xDEADBEEF}
+	c := hashdict.NewCracker(tokens, targets, 3)
+
+	found := make(map[uint32][]string)
+	units := 0
+	c.Run(c.Units(), 4, nil, func(hash uint32, label string) {
+		found[hash] = append(found[hash], label)
+	}, func(hashdict.Unit) {
+		units++
+	})
+
+	if units != 3*len(tokens) {
+		t.Errorf("Expected %d units, got %d", 3*len(tokens), units)
+	}
+	if len(found) != 2 || found[targets[0]][0] != "TXT_CAR_DESC" || found[targets[1]][0] != "GM_WORLD" {
+		t.Errorf("Unexpected candidates: %v", found)
+	}
+}
+
+func TestCrackResume(t *testing.T) {
+	tokens := hashdict.Tokens([]string{"GM_CAR_DESC", "TXT_HELLO", "TXT_WORLD"})
+	c := hashdict.NewCracker(tokens, []uint32{lib.BinHash("TXT_CAR_DESC")}, 3)
+
+	stop := make(chan struct{})
+	close(stop)
+	units := 0
+	c.Run(c.Units(), 1, stop, func(uint32, string) {}, func(hashdict.Unit) {
+		units++
+	})
+	if units > 1 {
+		t.Errorf("Expected at most one unit after stopping, got %d", units)
+	}
+
+	found := make(hashdict.Candidates)
+	for i := 0; i < 2; i++ {
+		c.Run(c.Units(), 2, nil, func(hash uint32, label string) {
+			found.Add(hash, label)
+		}, func(hashdict.Unit) {})
+	}
+	key := hashdict.HexKey(lib.BinHash("TXT_CAR_DESC"))
+	if len(found) != 1 || len(found[key]) != 1 || found[key][0] != "TXT_CAR_DESC" {
+		t.Errorf("Unexpected candidates after searching twice: %v", found)
+	}
+}
+
+func TestCollisions(t *testing.T) {
+	// "Aa" and "B@" hash the same: ('A'-33)*33+'a' == ('B'-33)*33+'@'
+	keys := []string{"B@", "TXT_HELLO", "Aa", "0x00000481", "TXT_HELLO"}
+	if lib.BinHash("Aa") != lib.BinHash("B@") || lib.BinHash("Aa") != 0x481 {
+		t.Fatalf("Test labels don't collide")
+	}
+
+	c := hashdict.Collisions(keys)
+	if len(c) != 1 || strings.Join(c[0].Keys, " ") != "0x00000481 Aa B@" {
+		t.Errorf("Unexpected collisions: %v", c)
+	}
+
+	if k, ok := hashdict.Collides(keys[1:2], "TXT_HELLO"); ok {
+		t.Errorf("Label collides with itself: %s", k)
+	}
+	if k, ok := hashdict.Collides(keys, "Aa"); !ok || k != "B@" {
+		t.Errorf("Expected Aa to collide with B@, got %s", k)
+	}
+}
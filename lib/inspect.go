@@ -0,0 +1,195 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package lib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/charmap"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/chunk"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/xor"
+	"io"
+)
+
+// ChunkInfo describes a chunk of a file. Offset is the file offset of the
+// chunk header.
+type ChunkInfo struct {
+	Offset int
+	ID     uint32
+	Length int
+}
+
+// StringHeader holds the header fields of a string chunk payload.
+type StringHeader struct {
+	Count         uint32
+	TableOffset   uint32
+	StringsOffset uint32
+	Name          string
+}
+
+// TableEntry is an entry of the hash table of a string chunk. Offset is
+// relative to the start of the strings. Err is set if the string can't be
+// read or decoded, Raw then holds whatever bytes could be read.
+type TableEntry struct {
+	Hash   uint32
+	Offset uint32
+	Raw    []byte
+	String string
+	Err    error
+}
+
+// FileInfo describes the layout of a file.
+type FileInfo struct {
+	Encoding Encoding
+	Size     int
+	Chunks   []ChunkInfo
+	Strings  StringHeader
+	// Table is the hash table in file order.
+	Table   []TableEntry
+	CharMap *charmap.Charmap
+	// Errors holds the problems found outside of the table entries.
+	Errors []error
+}
+
+// Inspect describes the layout of a file straight from its chunks and hash
+// table. Unlike Parse it doesn't give up on a malformed file: problems are
+// recorded in the FileInfo and only an unreadable header is an error.
+func Inspect(data []byte) (*FileInfo, error) {
+	enc, err := detectEncoding(data, int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	if enc == EncodingXor {
+		data = xor.Decode(data)
+	}
+
+	info := &FileInfo{
+		Encoding: enc,
+		Size:     len(data),
+		Chunks:   make([]ChunkInfo, 0),
+		Table:    make([]TableEntry, 0),
+		Errors:   make([]error, 0),
+	}
+
+	cr := chunk.NewReader(bytes.NewReader(data))
+	var strChunk *chunk.Chunk
+	strOffset := 0
+	for {
+		offset := int(cr.Offset())
+		c, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		if c == nil && strChunk != nil && info.CharMap != nil && zeroTail(data[offset:]) {
+			break
+		}
+		if c == nil {
+			info.Errors = append(info.Errors, &ParseError{Offset: offset, Err: ErrTruncatedHeader})
+			break
+		}
+		info.Chunks = append(info.Chunks, ChunkInfo{Offset: offset, ID: c.ID, Length: len(c.Data)})
+		if err != nil {
+			info.Errors = append(info.Errors, &ParseError{Offset: offset + 4, Err: ErrTruncatedChunk})
+		}
+
+		switch c.ID {
+		case StringChunkID:
+			if strChunk == nil {
+				strChunk = c
+				strOffset = offset + 8
+			}
+		case CharMapChunkID:
+			if info.CharMap == nil {
+				chm, err := charmap.ParseChunk(c.Data)
+				if err != nil {
+					info.Errors = append(info.Errors, &ParseError{Offset: offset + 8, Err: err})
+				}
+				info.CharMap = chm
+			}
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	if info.CharMap == nil {
+		info.Errors = append(info.Errors, &ParseError{Offset: int(cr.Offset()), Err: ErrMissingCharMap})
+	}
+	if strChunk == nil {
+		info.Errors = append(info.Errors, &ParseError{Offset: 0, Err: ErrBadChunkID})
+		return info, nil
+	}
+
+	info.inspectStrings(strChunk.Data, strOffset)
+	return info, nil
+}
+
+// inspectStrings reads the header and hash table of a string chunk payload.
+// base is the file offset of the payload.
+func (info *FileInfo) inspectStrings(data []byte, base int) {
+	if len(data) < stringHeaderSize {
+		info.Errors = append(info.Errors, &ParseError{Offset: base - 4, Err: ErrTruncatedHeader})
+		return
+	}
+
+	info.Strings = StringHeader{
+		Count:         binary.LittleEndian.Uint32(data[0:4]),
+		TableOffset:   binary.LittleEndian.Uint32(data[4:8]),
+		StringsOffset: binary.LittleEndian.Uint32(data[8:12]),
+		Name:          readName(data),
+	}
+
+	tableStart := uint64(info.Strings.TableOffset)
+	count := uint64(info.Strings.Count)
+	if tableStart > uint64(len(data)) {
+		count = 0
+	} else if available := (uint64(len(data)) - tableStart) / 8; count > available {
+		count = available
+	}
+	if count < uint64(info.Strings.Count) {
+		info.Errors = append(info.Errors, &ParseError{Offset: base, Err: ErrTruncatedHeader})
+	}
+	stringsStart := uint64(info.Strings.StringsOffset)
+	if stringsStart > uint64(len(data)) {
+		info.Errors = append(info.Errors, &ParseError{Offset: base + 8, Err: ErrStringOutOfRange})
+	}
+
+	for i := 0; i < int(count); i++ {
+		info.Table = append(info.Table, info.inspectEntry(data, base, int(tableStart)+i*8, stringsStart))
+	}
+}
+
+// inspectEntry reads the table entry at payload offset at and its string.
+func (info *FileInfo) inspectEntry(data []byte, base int, at int, stringsStart uint64) TableEntry {
+	e := TableEntry{
+		Hash:   binary.LittleEndian.Uint32(data[at : at+4]),
+		Offset: binary.LittleEndian.Uint32(data[at+4 : at+8]),
+	}
+
+	strStart := stringsStart + uint64(e.Offset)
+	if strStart >= uint64(len(data)) {
+		e.Err = &ParseError{Offset: base + at + 4, Err: ErrStringOutOfRange}
+		return e
+	}
+
+	strBytes, ok := ztString(data[strStart:])
+	if !ok {
+		e.Raw = data[strStart:]
+		e.Err = &ParseError{Offset: base + int(strStart), Err: ErrUnterminatedString}
+		return e
+	}
+
+	e.Raw = strBytes
+	if info.CharMap != nil {
+		str, err := info.CharMap.Decode(strBytes)
+		if de, ok := err.(*charmap.DecodeError); ok {
+			de.Label = entryLabel(nil, e.Hash)
+			e.Err = &ParseError{Offset: base + int(strStart) + de.Offset, Err: de}
+		}
+		e.String = str
+	}
+	return e
+}
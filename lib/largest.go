@@ -0,0 +1,158 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package lib
+
+import (
+	"github.com/WorldUnitedNFS/worldlangedit/lib/charmap"
+	"sort"
+)
+
+// largestPadding is the first rune used to fill single byte slots of a Largest
+// charmap that no rune may take.
+const largestPadding = 0xE000
+
+// BuildLargest returns the Largest pack of packs: for every hash in packs, the
+// string with the longest encoding in its own pack. The game uses the Largest
+// pack to size its text buffers, so no entry may encode shorter than it does in
+// its own pack. Runes that take two bytes in any pack never get a single byte
+// slot in the Largest charmap. When the runes of all packs don't fit into the
+// table, the ones that don't are replaced by a two byte rune in the Largest
+// strings and returned as overflow.
+func BuildLargest(packs []*LangFile) (largest *LangFile, overflow []rune, err error) {
+	type longest struct {
+		String string
+		Length int
+	}
+	found := make(map[uint32]longest)
+	twoByte := make(map[rune]bool)
+
+	for _, p := range packs {
+		for _, e := range p.Entries {
+			l := 0
+			for i, c := range []rune(e.String) {
+				b, err := p.CharMap.Encode(string(c))
+				if err != nil {
+					return nil, nil, &charmap.EncodeError{Rune: c, Pos: i, Label: entryLabel(nil, e.Hash)}
+				}
+				if len(b) > 1 {
+					twoByte[c] = true
+				}
+				l += len(b)
+			}
+			if cur, exists := found[e.Hash]; !exists || l > cur.Length {
+				found[e.Hash] = longest{String: e.String, Length: l}
+			}
+		}
+	}
+
+	largest = &LangFile{
+		Entries: make([]LangFileEntry, 0, len(found)),
+	}
+	strs := make([]string, 0, len(found))
+	for h, l := range found {
+		largest.Entries = append(largest.Entries, LangFileEntry{
+			Hash:   h,
+			String: l.String,
+		})
+		strs = append(strs, l.String)
+	}
+	sort.Slice(largest.Entries, func(i, j int) bool {
+		return largest.Entries[i].Hash < largest.Entries[j].Hash
+	})
+
+	single := make([]rune, 0)
+	double := make([]rune, 0)
+	used := make(map[rune]bool)
+	for _, c := range charmap.Runes(nil, strs) {
+		used[c] = true
+		if twoByte[c] {
+			double = append(double, c)
+		} else {
+			single = append(single, c)
+		}
+	}
+
+	// pad the single byte slots so none of them is left to a two byte rune
+	padding := make([]rune, 0)
+	next := rune(largestPadding)
+	for len(double) > 0 && len(single)+len(padding) < charmap.PlanCapacity(len(single)+len(padding)+len(double)).SingleByteSlots {
+		for used[next] {
+			next++
+		}
+		padding = append(padding, next)
+		next++
+	}
+
+	chars := append(append(single, padding...), double...)
+	if max := charmap.PlanCapacity(len(chars)).MaxRunes; len(chars) > max {
+		overflow = chars[max:]
+		chars = chars[:max]
+		replaceRunes(largest, overflow, chars[max-1])
+	}
+
+	largest.CharMap, err = charmap.Build(chars)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return largest, overflow, nil
+}
+
+// replaceRunes replaces every rune of runes in the strings of lf with r.
+func replaceRunes(lf *LangFile, runes []rune, r rune) {
+	replaced := make(map[rune]bool, len(runes))
+	for _, c := range runes {
+		replaced[c] = true
+	}
+
+	for i, e := range lf.Entries {
+		s := []rune(e.String)
+		for j, c := range s {
+			if replaced[c] {
+				s[j] = r
+			}
+		}
+		lf.Entries[i].String = string(s)
+	}
+}
+
+// LargestViolation is a string that is longer than its entry in the Largest
+// pack. Largest is -1 if the Largest pack has no entry for the hash.
+type LargestViolation struct {
+	Hash    uint32
+	Length  int
+	Largest int
+}
+
+// CheckLargest returns every string in pack whose encoding is longer than
+// that of the matching entry in largest. It fails on the first string that
+// can't be encoded with the charmap of its file.
+func CheckLargest(largest *LangFile, pack *LangFile) ([]LargestViolation, error) {
+	violations := make([]LargestViolation, 0)
+
+	for _, e := range pack.Entries {
+		b, err := encodeEntry(pack.CharMap, &e, nil)
+		if err != nil {
+			return nil, err
+		}
+		max := -1
+		if le := largest.Get(e.Hash); le != nil {
+			lb, err := encodeEntry(largest.CharMap, le, nil)
+			if err != nil {
+				return nil, err
+			}
+			max = len(lb)
+		}
+		if len(b) > max {
+			violations = append(violations, LargestViolation{
+				Hash:    e.Hash,
+				Length:  len(b),
+				Largest: max,
+			})
+		}
+	}
+
+	return violations, nil
+}
@@ -0,0 +1,133 @@
+package lib_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/WorldUnitedNFS/worldlangedit/lib"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/charmap"
+)
+
+func TestBuildLargest(t *testing.T) {
+	cm := &charmap.Charmap{NumEntries: 0x80}
+	english := &lib.LangFile{CharMap: cm}
+	english.Set(1, "Hello")
+	english.Set(2, "A very long string")
+	german := &lib.LangFile{CharMap: cm}
+	german.Set(1, "Guten Tag")
+	german.Set(2, "Kurz")
+	german.Set(3, "Nur Deutsch")
+
+	largest, overflow, err := lib.BuildLargest([]*lib.LangFile{english, german})
+	if err != nil {
+		t.Fatalf("Failed to build Largest: %v", err)
+	}
+	if len(overflow) != 0 {
+		t.Errorf("Unexpected overflow: %v", overflow)
+	}
+
+	expected := map[uint32]string{1: "Guten Tag", 2: "A very long string", 3: "Nur Deutsch"}
+	if largest.Len() != len(expected) {
+		t.Errorf("Expected %d entries, got %d", len(expected), largest.Len())
+	}
+	for h, s := range expected {
+		if e := largest.Get(h); e == nil || e.String != s {
+			t.Errorf("Expected %s for hash %d, got %v", s, h, e)
+		}
+	}
+
+	for _, p := range []*lib.LangFile{english, german} {
+		if v, err := lib.CheckLargest(largest, p); err != nil || len(v) != 0 {
+			t.Errorf("Unexpected violations: %v %v", v, err)
+		}
+	}
+
+	german.Set(1, "Guten Tag, wie geht es?")
+	v, err := lib.CheckLargest(largest, german)
+	if err != nil || len(v) != 1 || v[0].Hash != 1 || v[0].Largest != len("Guten Tag") {
+		t.Errorf("Expected one violation for hash 1, got %v", v)
+	}
+}
+
+func TestLargestUnencodable(t *testing.T) {
+	english := &lib.LangFile{CharMap: &charmap.Charmap{NumEntries: 0x80}}
+	english.Set(1, "Hello")
+	german := &lib.LangFile{CharMap: &charmap.Charmap{NumEntries: 0x80}}
+	german.Set(1, "Gr\u00FC\u00DF")
+
+	var ee *charmap.EncodeError
+	if _, _, err := lib.BuildLargest([]*lib.LangFile{english, german}); !errors.As(err, &ee) || ee.Rune != '\u00FC' {
+		t.Errorf("Expected an encode error for \u00FC, got %v", err)
+	}
+	if _, err := lib.CheckLargest(english, german); !errors.As(err, &ee) || ee.Pos != 2 {
+		t.Errorf("Expected an encode error at position 2, got %v", err)
+	}
+}
+
+// runePack returns a pack with a single rune string per hash, starting at
+// hash first, and a charmap laid out in the order of the runes.
+func runePack(t *testing.T, first uint32, runes []rune) *lib.LangFile {
+	cm, err := charmap.Build(runes)
+	if err != nil {
+		t.Fatalf("Failed to build charmap: %v", err)
+	}
+	lf := &lib.LangFile{CharMap: cm}
+	for i, r := range runes {
+		lf.Set(first+uint32(i), string(r))
+	}
+	return lf
+}
+
+func runeRange(first rune, n int) []rune {
+	runes := make([]rune, n)
+	for i := range runes {
+		runes[i] = first + rune(i)
+	}
+	return runes
+}
+
+func TestBuildLargestTwoByte(t *testing.T) {
+	// the Cyrillic runes come after the single byte slots of the pack
+	mixed := runePack(t, 1, append(runeRange(0x4E00, 0x80), runeRange(0x410, 4)...))
+	mixed.Set(0x1000, "\u0410\u0411\u0412\u0413")
+	english := &lib.LangFile{CharMap: &charmap.Charmap{NumEntries: 0x80}}
+	english.Set(0x1000, "Hello")
+
+	largest, _, err := lib.BuildLargest([]*lib.LangFile{english, mixed})
+	if err != nil {
+		t.Fatalf("Failed to build Largest: %v", err)
+	}
+
+	if e := largest.Get(0x1000); e == nil || e.String != "\u0410\u0411\u0412\u0413" {
+		t.Fatalf("Expected the Cyrillic string, got %v", e)
+	}
+	for _, p := range []*lib.LangFile{english, mixed} {
+		if v, err := lib.CheckLargest(largest, p); err != nil || len(v) != 0 {
+			t.Errorf("Unexpected violations: %v %v", v, err)
+		}
+	}
+}
+
+func TestBuildLargestOverflow(t *testing.T) {
+	cjk := runePack(t, 0, runeRange(0x4E00, 2500))
+	cyrillic := runePack(t, 0x10000, runeRange(0x400, 0x100))
+	hangul := runePack(t, 0x20000, runeRange(0xAC00, 2500))
+	packs := []*lib.LangFile{cjk, cyrillic, hangul}
+
+	largest, overflow, err := lib.BuildLargest(packs)
+	if err != nil {
+		t.Fatalf("Failed to build Largest: %v", err)
+	}
+
+	if len(overflow) == 0 {
+		t.Errorf("Expected overflow")
+	}
+	if largest.Len() != 2500+0x100+2500 {
+		t.Errorf("Expected %d entries, got %d", 2500+0x100+2500, largest.Len())
+	}
+	for _, p := range packs {
+		if v, err := lib.CheckLargest(largest, p); err != nil || len(v) != 0 {
+			t.Errorf("Unexpected violations: %d %v", len(v), err)
+		}
+	}
+}
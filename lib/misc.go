@@ -4,11 +4,145 @@
 
 package lib
 
-import "bytes"
+import (
+	"encoding/binary"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/xor"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultName is the name field written for packs that don't have one.
+const DefaultName = "Global"
+
+// SplitFileName splits a pack file name such as "Chinese_Simp_Global.bin" into
+// its language ("Chinese_Simp") and pack ("Global") parts.
+func SplitFileName(fn string) (lang string, pack string, ok bool) {
+	base := filepath.Base(fn)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	i := strings.LastIndexByte(base, '_')
+	if i <= 0 || i == len(base)-1 {
+		return "", "", false
+	}
+	return base[:i], base[i+1:], true
+}
+
+type Encoding int
+
+const (
+	EncodingUnknown Encoding = iota
+	EncodingPlain
+	EncodingXor
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case EncodingPlain:
+		return "plain"
+	case EncodingXor:
+		return "xor"
+	default:
+		return "unknown"
+	}
+}
+
+// number of header bytes looked at by DetectEncoding
+const detectSize = 16
 
 func IsFileEncoded(b []byte) bool {
-	return !bytes.Equal(
-		b[20:26],
-		[]byte{'G', 'l', 'o', 'b', 'a', 'l'},
-	)
+	enc, _ := DetectEncoding(b)
+	return enc == EncodingXor
+}
+
+// DetectEncoding checks whether b starts with a chunk stream that leads to a
+// string chunk, either as is or after XOR decoding. Chunks before the string
+// chunk are skipped as long as they fit into b.
+func DetectEncoding(b []byte) (Encoding, error) {
+	return detectEncoding(b, int64(len(b)))
+}
+
+// detectEncoding is DetectEncoding for the start b of a file of the given
+// size. A negative size skips the check of the string chunk length against
+// the file size.
+func detectEncoding(b []byte, size int64) (Encoding, error) {
+	if len(b) < 8 {
+		return EncodingUnknown, &ParseError{Offset: len(b), Err: ErrTruncatedHeader}
+	}
+
+	plainErr := findStringHeader(b, size, false)
+	if plainErr == nil {
+		return EncodingPlain, nil
+	}
+	xorErr := findStringHeader(b, size, true)
+	if xorErr == nil {
+		return EncodingXor, nil
+	}
+
+	// report the problem with whichever form got to a string chunk
+	if plainErr.Err == ErrBadChunkID {
+		return EncodingUnknown, xorErr
+	}
+	return EncodingUnknown, plainErr
+}
+
+// findStringHeader walks the chunk headers of b until it reaches a string
+// chunk and checks its header. It returns ErrBadChunkID if a chunk runs past
+// the end of b before a string chunk is found.
+func findStringHeader(b []byte, size int64, xored bool) *ParseError {
+	offset := 0
+	for {
+		h := window(b, offset, detectSize, xored)
+		if len(h) < 8 {
+			return &ParseError{Offset: 0, Err: ErrBadChunkID}
+		}
+		if binary.LittleEndian.Uint32(h[0:4]) == StringChunkID {
+			if err := checkStringHeader(h, size-int64(offset)); err != nil {
+				err.Offset += offset
+				return err
+			}
+			return nil
+		}
+
+		next := int64(offset) + 8 + int64(binary.LittleEndian.Uint32(h[4:8]))
+		if next > int64(len(b)) {
+			return &ParseError{Offset: 0, Err: ErrBadChunkID}
+		}
+		offset = int(next)
+	}
+}
+
+// window returns up to n bytes of b starting at offset, XOR-decoded if xored
+// is set.
+func window(b []byte, offset int, n int, xored bool) []byte {
+	if offset >= len(b) {
+		return nil
+	}
+	end := offset + n
+	if end > len(b) {
+		end = len(b)
+	}
+	if !xored {
+		return b[offset:end]
+	}
+	if offset == 0 {
+		return xor.Decode(b[:end])
+	}
+	// every byte is decoded with the one before it
+	return xor.Decode(b[offset-1 : end])[1:]
+}
+
+func checkStringHeader(h []byte, size int64) *ParseError {
+	if binary.LittleEndian.Uint32(h[0:4]) != StringChunkID {
+		return &ParseError{Offset: 0, Err: ErrBadChunkID}
+	}
+	if len(h) < detectSize {
+		return &ParseError{Offset: len(h), Err: ErrTruncatedHeader}
+	}
+	chunkLen := int64(binary.LittleEndian.Uint32(h[4:8]))
+	if chunkLen < stringHeaderSize || (size >= 0 && chunkLen+8 > size) {
+		return &ParseError{Offset: 4, Err: ErrTruncatedHeader}
+	}
+	if binary.LittleEndian.Uint32(h[12:16]) != stringHeaderSize {
+		return &ParseError{Offset: 12, Err: ErrBadTableOffset}
+	}
+	return nil
 }
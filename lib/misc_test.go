@@ -0,0 +1,78 @@
+package lib_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/WorldUnitedNFS/worldlangedit/lib"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/xor"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	plain := testPack()
+	// a name field other than "Global" must not affect detection
+	copy(plain[20:36], []byte("Career\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"))
+
+	if enc, err := lib.DetectEncoding(plain); enc != lib.EncodingPlain || err != nil {
+		t.Errorf("Expected plain, got %v (%v)", enc, err)
+	}
+	if enc, err := lib.DetectEncoding(xor.Encode(plain)); enc != lib.EncodingXor || err != nil {
+		t.Errorf("Expected xor, got %v (%v)", enc, err)
+	}
+	if _, err := lib.Parse(xor.Encode(plain)); err != nil {
+		t.Errorf("Failed to parse encoded file: %v", err)
+	}
+
+	enc, err := lib.DetectEncoding(plain[:10])
+	if enc != lib.EncodingUnknown || !errors.Is(err, lib.ErrTruncatedHeader) {
+		t.Errorf("Expected unknown with %v, got %v (%v)", lib.ErrTruncatedHeader, enc, err)
+	}
+
+	zip := append([]byte("PK\x03\x04\x14\x00\x00\x00"), make([]byte, 56)...)
+	for _, b := range [][]byte{[]byte("this is not a language pack"), make([]byte, 64), zip} {
+		enc, err = lib.DetectEncoding(b)
+		if enc != lib.EncodingUnknown || !errors.Is(err, lib.ErrBadChunkID) {
+			t.Errorf("Expected unknown with %v for %q, got %v (%v)", lib.ErrBadChunkID, b[:8], enc, err)
+		}
+	}
+}
+
+func TestSplitFileName(t *testing.T) {
+	tests := []struct {
+		fn, lang, pack string
+		ok             bool
+	}{
+		{"English_Global.bin", "English", "Global", true},
+		{"LANGUAGES/Chinese_Simp_Global.bin", "Chinese_Simp", "Global", true},
+		{"Labels_Career.json", "Labels", "Career", true},
+		{"Global.bin", "", "", false},
+	}
+
+	for _, tt := range tests {
+		lang, pack, ok := lib.SplitFileName(tt.fn)
+		if lang != tt.lang || pack != tt.pack || ok != tt.ok {
+			t.Errorf("SplitFileName(%q) = %q, %q, %v", tt.fn, lang, pack, ok)
+		}
+	}
+}
+
+func TestNameRoundTrip(t *testing.T) {
+	lf, err := lib.Parse(testPack())
+	if err != nil {
+		t.Fatalf("Failed to parse file: %v", err)
+	}
+	if lf.Name != lib.DefaultName {
+		t.Errorf("Expected name %s, got %s", lib.DefaultName, lf.Name)
+	}
+
+	lf.Name = "Career"
+	for _, data := range [][]byte{lib.SaveFile(lf, lf, false), lib.SaveFilePreserving(lf, false)} {
+		saved, err := lib.Parse(data)
+		if err != nil {
+			t.Fatalf("Failed to parse saved file: %v", err)
+		}
+		if saved.Name != "Career" {
+			t.Errorf("Expected name Career, got %s", saved.Name)
+		}
+	}
+}
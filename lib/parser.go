@@ -5,62 +5,204 @@
 package lib
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/binary"
 	"github.com/WorldUnitedNFS/worldlangedit/lib/charmap"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/chunk"
 	"github.com/WorldUnitedNFS/worldlangedit/lib/xor"
+	"io"
 )
 
-func ztString(b []byte) []byte {
-	var sb []byte
+const (
+	StringChunkID  = 0x39000
+	CharMapChunkID = 0x39001
+
+	headerSize = 36
+	// size of the string chunk payload before the hash table
+	stringHeaderSize = headerSize - 8
+	nameSize         = 16
+)
+
+func ztString(b []byte) ([]byte, bool) {
 	for i, c := range b {
 		if c == 0 {
-			sb = b[:i]
-			break
+			return b[:i], true
 		}
 	}
-	return sb
+	return nil, false
 }
 
 func ParseFile(data []byte) *LangFile {
-	if IsFileEncoded(data) {
-		data = xor.Decode(data)
+	lf, err := Parse(data)
+	if err != nil {
+		panic(err)
 	}
+	return lf
+}
 
-	chunkLen := binary.LittleEndian.Uint32(data[4:8])
-	entryCount := binary.LittleEndian.Uint32(data[8:12])
-	entries := make([]LangFileEntry, int(entryCount))
+// Parse is like ParseFile, but returns a *ParseError instead of panicking
+// when the file is truncated or malformed.
+func Parse(data []byte) (*LangFile, error) {
+	return decode(bytes.NewReader(data), data, int64(len(data)))
+}
+
+// Decode reads a language file from r, decoding it on the fly if it is
+// XOR-encoded. The string chunk has to start within the first streamWindow
+// bytes of the stream.
+func Decode(r io.Reader) (*LangFile, error) {
+	br := bufio.NewReaderSize(r, streamWindow)
+	header, _ := br.Peek(streamWindow)
+	return decode(br, header, -1)
+}
 
-	stringsStart := binary.LittleEndian.Uint32(data[16:20])
+// streamWindow is the number of bytes Decode looks at to detect the encoding.
+const streamWindow = 64 << 10
 
-	offset := int(chunkLen) + 8
-	// Chunk type 0 -> padding
-	if binary.LittleEndian.Uint32(data[offset:offset+4]) == 0 {
-		offset += int(binary.LittleEndian.Uint32(data[offset+4:offset+8])) + 8
+// zeroTail reports whether b only holds zero bytes. Some packs are padded to
+// a size that leaves a few bytes after the last chunk.
+func zeroTail(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
 	}
-	endData := data[offset+8:]
-	chm := charmap.FromChunk(endData)
+	return true
+}
 
-	offset = 36
-	entryIdx := 0
+// decode reads a language file from r. header holds the start of the stream
+// and size its length, or -1 if it is not known.
+func decode(r io.Reader, header []byte, size int64) (*LangFile, error) {
+	if len(header) < headerSize {
+		return nil, &ParseError{Offset: len(header), Err: ErrTruncatedHeader}
+	}
+	enc, err := detectEncoding(header, size)
+	if err != nil {
+		return nil, err
+	}
+	src := r
+	if enc == EncodingXor {
+		src = xor.NewReader(r)
+	}
+	br := bufio.NewReader(src)
+
+	cr := chunk.NewReader(br)
+	chunks := make([]chunk.Chunk, 0)
+	var strChunk *chunk.Chunk
+	var strOffset int
+	var chm *charmap.Charmap
 	for {
-		hash := binary.LittleEndian.Uint32(data[offset : offset+4])
-		location := binary.LittleEndian.Uint32(data[offset+4 : offset+8])
-		strBytes := ztString(data[stringsStart+8+location:])
-		entries[entryIdx] = LangFileEntry{
-			Hash:          hash,
-			String:        chm.DecodeBytes(strBytes),
-			OriginalBytes: strBytes,
-			Offset:        location,
+		offset := int(cr.Offset())
+		if strChunk != nil && chm != nil {
+			if rest, _ := br.Peek(8); len(rest) < 8 && zeroTail(rest) {
+				break
+			}
 		}
-		entryIdx++
-		offset += 8
-		if offset == int(stringsStart+8) {
+		c, err := cr.Next()
+		if err == io.EOF {
 			break
 		}
+		if err != nil {
+			switch {
+			case c == nil || c.ID == StringChunkID:
+				return nil, &ParseError{Offset: offset + 4, Err: ErrTruncatedHeader}
+			case c.ID == CharMapChunkID:
+				return nil, &ParseError{Offset: offset + 8, Err: charmap.ErrShortChunk}
+			case chm == nil:
+				return nil, &ParseError{Offset: offset, Err: ErrMissingCharMap}
+			default:
+				return nil, &ParseError{Offset: offset + 4, Err: ErrTruncatedChunk}
+			}
+		}
+		chunks = append(chunks, *c)
+
+		switch c.ID {
+		case StringChunkID:
+			if strChunk == nil {
+				strChunk = c
+				strOffset = offset + 8
+			}
+		case CharMapChunkID:
+			if chm == nil {
+				chm, err = charmap.ParseChunk(c.Data)
+				if err != nil {
+					return nil, &ParseError{Offset: offset + 8, Err: err}
+				}
+			}
+		}
+	}
+
+	if strChunk == nil {
+		return nil, &ParseError{Offset: 0, Err: ErrBadChunkID}
+	}
+	if chm == nil {
+		return nil, &ParseError{Offset: int(cr.Offset()), Err: ErrMissingCharMap}
+	}
+
+	entries, err := parseStrings(strChunk.Data, strOffset, chm)
+	if err != nil {
+		return nil, err
 	}
 
 	return &LangFile{
+		Name:    readName(strChunk.Data),
 		Entries: entries,
 		CharMap: chm,
+		Chunks:  chunks,
+	}, nil
+}
+
+// readName returns the name field of a string chunk payload.
+func readName(data []byte) string {
+	field := data[12 : 12+nameSize]
+	if name, ok := ztString(field); ok {
+		return string(name)
+	}
+	return string(field)
+}
+
+// parseStrings reads the entries out of a string chunk payload. base is the
+// file offset of the payload and is only used for error reporting.
+func parseStrings(data []byte, base int, chm *charmap.Charmap) ([]LangFileEntry, error) {
+	if len(data) < stringHeaderSize {
+		return nil, &ParseError{Offset: base - 4, Err: ErrTruncatedHeader}
+	}
+
+	entryCount := binary.LittleEndian.Uint32(data[0:4])
+	tableStart := uint64(binary.LittleEndian.Uint32(data[4:8]))
+	if tableStart+uint64(entryCount)*8 > uint64(len(data)) {
+		return nil, &ParseError{Offset: base, Err: ErrTruncatedHeader}
+	}
+	stringsStart := uint64(binary.LittleEndian.Uint32(data[8:12]))
+	if stringsStart > uint64(len(data)) {
+		return nil, &ParseError{Offset: base + 8, Err: ErrStringOutOfRange}
 	}
+
+	entries := make([]LangFileEntry, int(entryCount))
+	for i := range entries {
+		at := int(tableStart) + i*8
+		hash := binary.LittleEndian.Uint32(data[at : at+4])
+		location := binary.LittleEndian.Uint32(data[at+4 : at+8])
+		strStart := stringsStart + uint64(location)
+		if strStart >= uint64(len(data)) {
+			return nil, &ParseError{Offset: base + at + 4, Err: ErrStringOutOfRange}
+		}
+		strBytes, ok := ztString(data[strStart:])
+		if !ok {
+			return nil, &ParseError{Offset: base + int(strStart), Err: ErrUnterminatedString}
+		}
+		str, err := chm.Decode(strBytes)
+		if de, ok := err.(*charmap.DecodeError); ok {
+			de.Label = entryLabel(nil, hash)
+			return nil, &ParseError{Offset: base + int(strStart) + de.Offset, Err: de}
+		}
+		entries[i] = LangFileEntry{
+			Hash:          hash,
+			String:        str,
+			OriginalBytes: strBytes,
+			Offset:        location,
+		}
+	}
+
+	return entries, nil
 }
@@ -0,0 +1,269 @@
+package lib_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"testing/iotest"
+
+	"github.com/WorldUnitedNFS/worldlangedit/lib"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/charmap"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/chunk"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/xor"
+)
+
+func testPack() []byte {
+	lf := &lib.LangFile{
+		Entries: []lib.LangFileEntry{
+			{Hash: lib.BinHash("TXT_HELLO"), String: "Hello"},
+			{Hash: lib.BinHash("TXT_WORLD"), String: "World"},
+		},
+		CharMap: &charmap.Charmap{NumEntries: 0x80},
+	}
+	return lib.SaveFile(lf, lf, false)
+}
+
+func TestParseTruncated(t *testing.T) {
+	data := testPack()
+
+	if _, err := lib.Parse(data); err != nil {
+		t.Fatalf("Failed to parse valid file: %v", err)
+	}
+
+	tests := []struct {
+		length int
+		err    error
+	}{
+		{0, lib.ErrTruncatedHeader},
+		{20, lib.ErrTruncatedHeader},
+		{40, lib.ErrTruncatedHeader},
+		{len(data) - 0x1804 - 8, lib.ErrMissingCharMap},
+		{len(data) - 1, charmap.ErrShortChunk},
+	}
+
+	for _, tt := range tests {
+		_, err := lib.Parse(data[:tt.length])
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Parse of %d bytes: expected %v, got %v", tt.length, tt.err, err)
+		}
+		var pe *lib.ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("Parse of %d bytes: expected *ParseError, got %T", tt.length, err)
+		}
+	}
+}
+
+func TestParseZeroTail(t *testing.T) {
+	data := append(testPack(), 0, 0, 0, 0)
+
+	for _, d := range [][]byte{data, xor.Encode(data)} {
+		if _, err := lib.Parse(d); err != nil {
+			t.Errorf("Failed to parse file with a zero tail: %v", err)
+		}
+	}
+	if info, err := lib.Inspect(data); err != nil || len(info.Errors) != 0 {
+		t.Errorf("Unexpected inspect errors for a zero tail: %v %v", err, info.Errors)
+	}
+
+	data[len(data)-1] = 1
+	if _, err := lib.Parse(data); !errors.Is(err, lib.ErrTruncatedHeader) {
+		t.Errorf("Expected %v for a non-zero tail, got %v", lib.ErrTruncatedHeader, err)
+	}
+
+	noCharMap := append(testPack()[:len(data)-4-0x1804-8], 0, 0, 0, 0)
+	if _, err := lib.Parse(noCharMap); !errors.Is(err, lib.ErrTruncatedHeader) {
+		t.Errorf("Expected %v for a zero tail without charmap, got %v", lib.ErrTruncatedHeader, err)
+	}
+}
+
+func TestDecodeStream(t *testing.T) {
+	var buf bytes.Buffer
+	cw := chunk.NewWriter(&buf)
+	_ = cw.WriteChunk(chunk.Chunk{ID: 0x12345, Data: []byte("leading chunk")})
+	buf.Write(testPack())
+	buf.Write([]byte{0, 0, 0, 0})
+	plain := buf.Bytes()
+
+	for _, data := range [][]byte{plain, xor.Encode(plain)} {
+		// one byte at a time and without io.Seeker
+		lf, err := lib.Decode(iotest.OneByteReader(bytes.NewReader(data)))
+		if err != nil {
+			t.Errorf("Failed to decode stream: %v", err)
+			continue
+		}
+		if len(lf.Entries) != 2 || lf.Entries[0].String != "Hello" || lf.Chunks[0].ID != 0x12345 {
+			t.Errorf("Unexpected file: %v %v", lf.Entries, lf.Chunks[0].ID)
+		}
+	}
+
+	if _, err := lib.Decode(iotest.OneByteReader(bytes.NewReader(plain[:len(plain)-10]))); !errors.Is(err, charmap.ErrShortChunk) {
+		t.Errorf("Expected %v for a truncated stream, got %v", charmap.ErrShortChunk, err)
+	}
+}
+
+func TestParseBadChunkID(t *testing.T) {
+	data := testPack()
+	data[0] = 0x42
+
+	_, err := lib.Parse(data)
+	if !errors.Is(err, lib.ErrBadChunkID) {
+		t.Errorf("Expected %v, got %v", lib.ErrBadChunkID, err)
+	}
+}
+
+func TestParseLeadingChunk(t *testing.T) {
+	var buf bytes.Buffer
+	cw := chunk.NewWriter(&buf)
+	_ = cw.WriteChunk(chunk.Chunk{ID: 0x12345, Data: []byte("leading chunk")})
+	buf.Write(testPack())
+	plain := buf.Bytes()
+
+	tests := []struct {
+		data []byte
+		enc  lib.Encoding
+	}{
+		{plain, lib.EncodingPlain},
+		{xor.Encode(plain), lib.EncodingXor},
+	}
+
+	for _, tt := range tests {
+		if enc, err := lib.DetectEncoding(tt.data); enc != tt.enc || err != nil {
+			t.Errorf("Expected %v, got %v (%v)", tt.enc, enc, err)
+		}
+		lf, err := lib.Parse(tt.data)
+		if err != nil {
+			t.Errorf("Failed to parse %v file with a leading chunk: %v", tt.enc, err)
+			continue
+		}
+		if len(lf.Entries) != 2 || lf.Entries[0].String != "Hello" || lf.Chunks[0].ID != 0x12345 {
+			t.Errorf("Unexpected %v file: %v %v", tt.enc, lf.Entries, lf.Chunks[0].ID)
+		}
+	}
+}
+
+func TestUnknownChunksPreserved(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(testPack())
+	cw := chunk.NewWriter(&buf)
+	_ = cw.WriteChunk(chunk.Chunk{ID: 0x12345, Data: []byte("extra data")})
+
+	lf, err := lib.Parse(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to parse file with extra chunk: %v", err)
+	}
+
+	lf, err = lib.Parse(lib.SaveFile(lf, lf, true))
+	if err != nil {
+		t.Fatalf("Failed to parse saved file: %v", err)
+	}
+
+	for _, c := range lf.Chunks {
+		if c.ID == 0x12345 {
+			if string(c.Data) != "extra data" {
+				t.Errorf("Extra chunk changed. Expected 'extra data', got %q", c.Data)
+			}
+			return
+		}
+	}
+	t.Errorf("Extra chunk was not written back")
+}
+
+func TestDuplicateChunksPreserved(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(testPack())
+	extra := make([]byte, charmap.ChunkSize)
+	binary.LittleEndian.PutUint32(extra[0:4], 0x80)
+	copy(extra[4:], "second charmap")
+	cw := chunk.NewWriter(&buf)
+	_ = cw.WriteChunk(chunk.Chunk{ID: lib.CharMapChunkID, Data: extra})
+
+	lf, err := lib.Parse(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to parse file with a second charmap chunk: %v", err)
+	}
+	lf.CharMap.EntryTable[0x80] = 'ä'
+	lf.CharMap.NumEntries = 0x81
+
+	saves := map[string][]byte{
+		"SaveFile":           lib.SaveFile(lf, lf, false),
+		"SaveFilePreserving": lib.SaveFilePreserving(lf, false),
+	}
+	for name, data := range saves {
+		saved, err := lib.Parse(data)
+		if err != nil {
+			t.Errorf("Failed to parse file saved by %s: %v", name, err)
+			continue
+		}
+		if saved.CharMap.EntryTable[0x80] != 'ä' {
+			t.Errorf("%s did not write the changed charmap", name)
+		}
+
+		charMaps := make([][]byte, 0)
+		for _, c := range saved.Chunks {
+			if c.ID == lib.CharMapChunkID {
+				charMaps = append(charMaps, c.Data)
+			}
+		}
+		if len(charMaps) != 2 || !bytes.Equal(charMaps[1], extra) {
+			t.Errorf("%s did not keep the second charmap chunk as it was", name)
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	info, err := lib.Inspect(testPack())
+	if err != nil {
+		t.Fatalf("Failed to inspect: %v", err)
+	}
+
+	if len(info.Chunks) != 3 || info.Chunks[1].ID != chunk.PaddingID || info.Chunks[2].ID != lib.CharMapChunkID {
+		t.Fatalf("Unexpected chunks: %v", info.Chunks)
+	}
+	if info.Chunks[2].Offset != info.Chunks[1].Offset+8+info.Chunks[1].Length || info.Chunks[2].Length != charmap.ChunkSize {
+		t.Errorf("Unexpected charmap chunk: %v", info.Chunks[2])
+	}
+	if info.Strings.Count != 2 || info.Strings.TableOffset != 0x1C || info.Strings.StringsOffset != 0x1C+16 || info.Strings.Name != lib.DefaultName {
+		t.Errorf("Unexpected string header: %+v", info.Strings)
+	}
+	if len(info.Table) != 2 || string(info.Table[0].Raw) != info.Table[0].String || info.Table[0].Err != nil {
+		t.Errorf("Unexpected table: %v", info.Table)
+	}
+	if len(info.Errors) != 0 {
+		t.Errorf("Unexpected errors: %v", info.Errors)
+	}
+}
+
+func TestInspectCorrupt(t *testing.T) {
+	data := testPack()
+	info, err := lib.Inspect(data)
+	if err != nil {
+		t.Fatalf("Failed to inspect: %v", err)
+	}
+
+	// an undecodable byte in the first string and an out of range offset in
+	// the second entry, both offsets are relative to the string chunk payload
+	payload := data[8:]
+	payload[info.Strings.StringsOffset+info.Table[0].Offset] = 0x80
+	binary.LittleEndian.PutUint32(payload[info.Strings.TableOffset+12:], 0xFFFF)
+
+	if _, err := lib.Parse(data); err == nil {
+		t.Fatalf("Expected the corrupt pack to fail parsing")
+	}
+
+	info, err = lib.Inspect(data)
+	if err != nil {
+		t.Fatalf("Failed to inspect corrupt pack: %v", err)
+	}
+	if len(info.Table) != 2 || len(info.Errors) != 0 {
+		t.Fatalf("Expected two entries and no file errors, got %v and %v", info.Table, info.Errors)
+	}
+
+	var de *charmap.DecodeError
+	if !errors.As(info.Table[0].Err, &de) || info.Table[0].Raw[0] != 0x80 {
+		t.Errorf("Expected a decode error next to the raw bytes, got %v %v", info.Table[0].Err, info.Table[0].Raw)
+	}
+	if !errors.Is(info.Table[1].Err, lib.ErrStringOutOfRange) || info.Table[1].Raw != nil {
+		t.Errorf("Expected an out of range error, got %v", info.Table[1].Err)
+	}
+}
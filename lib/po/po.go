@@ -0,0 +1,384 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package po reads and writes gettext PO and POT files.
+package po
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var ErrPlural = errors.New("plural entries are not supported")
+
+type HeaderField struct {
+	Name  string
+	Value string
+}
+
+type Entry struct {
+	Comments          []string
+	ExtractedComments []string
+	References        []string
+	Flags             []string
+	// Previous holds the #| lines describing the previous msgid of a fuzzy
+	// entry, without the marker.
+	Previous []string
+
+	Context string
+	ID      string
+	Str     string
+
+	Obsolete bool
+}
+
+func (e *Entry) HasFlag(flag string) bool {
+	for _, f := range e.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Entry) Fuzzy() bool {
+	return e.HasFlag("fuzzy")
+}
+
+type File struct {
+	Header  []HeaderField
+	Entries []*Entry
+}
+
+// HeaderValue returns the value of a header field.
+func (f *File) HeaderValue(name string) (string, bool) {
+	for _, h := range f.Header {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value, true
+		}
+	}
+	return "", false
+}
+
+// SyntaxError is returned for malformed input. Line is 1-based.
+type SyntaxError struct {
+	Line int
+	Err  error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+type parser struct {
+	file  *File
+	entry *Entry
+	// the field continuation strings are appended to
+	field *string
+	// whether the entry has a msgstr and the next keyword starts a new one
+	complete bool
+}
+
+func (p *parser) flush() {
+	if p.entry == nil {
+		return
+	}
+	if p.entry.ID == "" && p.entry.Context == "" && !p.entry.Obsolete && len(p.file.Header) == 0 {
+		p.file.Header = parseHeader(p.entry.Str)
+	} else {
+		p.file.Entries = append(p.file.Entries, p.entry)
+	}
+	p.entry = nil
+	p.field = nil
+	p.complete = false
+}
+
+func (p *parser) current() *Entry {
+	if p.complete {
+		p.flush()
+	}
+	if p.entry == nil {
+		p.entry = &Entry{}
+	}
+	return p.entry
+}
+
+func (p *parser) keyword(line string, obsolete bool) error {
+	if strings.HasPrefix(line, "\"") {
+		if p.field == nil {
+			return errors.New("string without keyword")
+		}
+		s, err := unquote(line)
+		if err != nil {
+			return err
+		}
+		*p.field += s
+		return nil
+	}
+
+	i := strings.IndexAny(line, " \t")
+	if i < 0 {
+		return fmt.Errorf("missing string after %s", line)
+	}
+	kw := line[:i]
+	s, err := unquote(strings.TrimSpace(line[i:]))
+	if err != nil {
+		return err
+	}
+
+	var e *Entry
+	switch kw {
+	case "msgctxt", "msgid":
+		e = p.current()
+		if kw == "msgctxt" {
+			p.field = &e.Context
+		} else {
+			p.field = &e.ID
+		}
+	case "msgstr":
+		if p.entry == nil || p.complete {
+			return errors.New("msgstr without msgid")
+		}
+		e = p.entry
+		p.field = &e.Str
+		p.complete = true
+	case "msgid_plural":
+		return ErrPlural
+	default:
+		if strings.HasPrefix(kw, "msgstr[") {
+			return ErrPlural
+		}
+		return fmt.Errorf("unknown keyword %s", kw)
+	}
+
+	*p.field = s
+	e.Obsolete = obsolete
+	return nil
+}
+
+func (p *parser) line(line string) error {
+	switch {
+	case line == "":
+		if p.complete {
+			p.flush()
+		}
+		return nil
+	case strings.HasPrefix(line, "#~"):
+		rest := strings.TrimSpace(line[2:])
+		if strings.HasPrefix(rest, "|") {
+			e := p.current()
+			e.Previous = append(e.Previous, strings.TrimSpace(rest[1:]))
+			return nil
+		}
+		if rest == "" {
+			return nil
+		}
+		return p.keyword(rest, true)
+	case strings.HasPrefix(line, "#"):
+		e := p.current()
+		p.field = nil
+		text := ""
+		if len(line) > 2 {
+			text = strings.TrimSpace(line[2:])
+		}
+		switch {
+		case strings.HasPrefix(line, "#,"):
+			for _, f := range strings.Split(line[2:], ",") {
+				if f = strings.TrimSpace(f); f != "" {
+					e.Flags = append(e.Flags, f)
+				}
+			}
+		case strings.HasPrefix(line, "#."):
+			e.ExtractedComments = append(e.ExtractedComments, text)
+		case strings.HasPrefix(line, "#:"):
+			e.References = append(e.References, text)
+		case strings.HasPrefix(line, "#|"):
+			e.Previous = append(e.Previous, text)
+		default:
+			e.Comments = append(e.Comments, strings.TrimPrefix(line[1:], " "))
+		}
+		return nil
+	default:
+		return p.keyword(line, false)
+	}
+}
+
+// Parse reads a PO or POT file.
+func Parse(r io.Reader) (*File, error) {
+	p := &parser{file: &File{Entries: make([]*Entry, 0)}}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if n == 1 {
+			line = strings.TrimPrefix(line, "\uFEFF")
+		}
+		if err := p.line(line); err != nil {
+			return nil, &SyntaxError{Line: n, Err: err}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if p.entry != nil && !p.complete {
+		return nil, &SyntaxError{Line: n, Err: errors.New("entry without msgstr")}
+	}
+	p.flush()
+	return p.file, nil
+}
+
+func parseHeader(s string) []HeaderField {
+	fields := make([]HeaderField, 0)
+	for _, line := range strings.Split(s, "\n") {
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		fields = append(fields, HeaderField{
+			Name:  strings.TrimSpace(line[:i]),
+			Value: strings.TrimSpace(line[i+1:]),
+		})
+	}
+	return fields
+}
+
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("malformed string %s", s)
+	}
+	s = s[1 : len(s)-1]
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '"' {
+			return "", errors.New("unescaped quote in string")
+		}
+		if c != '\\' {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", errors.New("string ends with a backslash")
+		}
+		switch s[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'a':
+			sb.WriteByte('\a')
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'v':
+			sb.WriteByte('\v')
+		case '"', '\\':
+			sb.WriteByte(s[i])
+		default:
+			return "", fmt.Errorf("unknown escape sequence \\%c", s[i])
+		}
+	}
+	return sb.String(), nil
+}
+
+var escaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"\"", "\\\"",
+	"\n", "\\n",
+	"\t", "\\t",
+	"\r", "\\r",
+	"\a", "\\a",
+	"\b", "\\b",
+	"\f", "\\f",
+	"\v", "\\v",
+)
+
+type writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (w *writer) printf(format string, a ...interface{}) {
+	if w.err == nil {
+		_, w.err = fmt.Fprintf(w.w, format, a...)
+	}
+}
+
+// str writes a keyword and its string, split after every newline.
+func (w *writer) str(prefix string, kw string, s string) {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) <= 1 {
+		w.printf("%s%s \"%s\"\n", prefix, kw, escaper.Replace(s))
+		return
+	}
+	w.printf("%s%s \"\"\n", prefix, kw)
+	for _, l := range lines {
+		w.printf("%s\"%s\"\n", prefix, escaper.Replace(l))
+	}
+}
+
+func (w *writer) entry(e *Entry) {
+	for _, c := range e.Comments {
+		w.printf("# %s\n", c)
+	}
+	for _, c := range e.ExtractedComments {
+		w.printf("#. %s\n", c)
+	}
+	for _, c := range e.References {
+		w.printf("#: %s\n", c)
+	}
+	if len(e.Flags) > 0 {
+		w.printf("#, %s\n", strings.Join(e.Flags, ", "))
+	}
+	prefix := ""
+	if e.Obsolete {
+		prefix = "#~ "
+	}
+	for _, c := range e.Previous {
+		w.printf("%s#| %s\n", prefix, c)
+	}
+	if e.Context != "" {
+		w.str(prefix, "msgctxt", e.Context)
+	}
+	w.str(prefix, "msgid", e.ID)
+	w.str(prefix, "msgstr", e.Str)
+}
+
+// Write writes f in PO format.
+func (f *File) Write(w io.Writer) error {
+	pw := &writer{w: bufio.NewWriter(w)}
+
+	var header strings.Builder
+	for _, h := range f.Header {
+		header.WriteString(h.Name + ": " + h.Value + "\n")
+	}
+	pw.entry(&Entry{Str: header.String()})
+
+	for _, e := range f.Entries {
+		pw.printf("\n")
+		pw.entry(e)
+	}
+
+	if pw.err != nil {
+		return pw.err
+	}
+	return pw.w.Flush()
+}
@@ -0,0 +1,108 @@
+package po_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/WorldUnitedNFS/worldlangedit/lib/po"
+)
+
+const testPo = `# German translation
+msgid ""
+msgstr ""
+"Language: German\n"
+"Content-Type: text/plain; charset=UTF-8\n"
+
+#. hash 0x905FBB72
+msgctxt "TXT_HELLO"
+msgid "Hello"
+msgstr "Hallo"
+
+#, fuzzy, c-format
+#| msgid "World"
+msgctxt "TXT_WORLD"
+msgid ""
+"Whole\n"
+"World"
+msgstr "Ganze\tWelt \"!\""
+
+#~ msgctxt "TXT_OLD"
+#~ msgid "Old"
+#~ msgstr "Alt"
+`
+
+func TestParse(t *testing.T) {
+	f, err := po.Parse(strings.NewReader(testPo))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	if lang, _ := f.HeaderValue("language"); lang != "German" || len(f.Header) != 2 {
+		t.Errorf("Unexpected header: %v", f.Header)
+	}
+	if len(f.Entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(f.Entries))
+	}
+
+	e := f.Entries[0]
+	if e.Context != "TXT_HELLO" || e.ID != "Hello" || e.Str != "Hallo" || e.ExtractedComments[0] != "hash 0x905FBB72" || e.Fuzzy() {
+		t.Errorf("Unexpected entry: %+v", e)
+	}
+	e = f.Entries[1]
+	if e.ID != "Whole\nWorld" || e.Str != "Ganze\tWelt \"!\"" || !e.Fuzzy() || !e.HasFlag("c-format") || e.Previous[0] != "msgid \"World\"" {
+		t.Errorf("Unexpected entry: %+v", e)
+	}
+	e = f.Entries[2]
+	if !e.Obsolete || e.Context != "TXT_OLD" || e.Str != "Alt" {
+		t.Errorf("Unexpected obsolete entry: %+v", e)
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	f, err := po.Parse(strings.NewReader(testPo))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	f2, err := po.Parse(&buf)
+	if err != nil {
+		t.Fatalf("Failed to parse written file: %v\n%s", err, buf.String())
+	}
+
+	if len(f2.Header) != len(f.Header) || len(f2.Entries) != len(f.Entries) {
+		t.Fatalf("Written file differs: %v", buf.String())
+	}
+	for i, e := range f.Entries {
+		e2 := f2.Entries[i]
+		if e.Context != e2.Context || e.ID != e2.ID || e.Str != e2.Str || e.Fuzzy() != e2.Fuzzy() || e.Obsolete != e2.Obsolete {
+			t.Errorf("Entry %d differs: %+v != %+v", i, e, e2)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		po   string
+		line int
+	}{
+		{"msgid \"a\"\nmsgid_plural \"b\"\n", 2},
+		{"msgstr \"a\"\n", 1},
+		{"msgid \"a\nmsgstr \"\"\n", 1},
+		{"msgid \"a\"\n", 1},
+		{"msgid \"\\q\"\nmsgstr \"\"\n", 1},
+	}
+
+	for _, tt := range tests {
+		_, err := po.Parse(strings.NewReader(tt.po))
+		var se *po.SyntaxError
+		if !errors.As(err, &se) || se.Line != tt.line {
+			t.Errorf("%q: expected syntax error on line %d, got %v", tt.po, tt.line, err)
+		}
+	}
+}
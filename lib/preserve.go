@@ -0,0 +1,233 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package lib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/chunk"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/xor"
+	"io"
+	"sort"
+)
+
+// SaveFilePreserving saves a parsed file while keeping the layout of the
+// original. The hash table keeps its original order. New hashes are inserted
+// at their position if the table is sorted by hash and appended otherwise,
+// entries whose encoded string did not change keep their offset, changed and
+// new strings are appended to the string table, and the original padding is
+// reused wherever the chunk offsets did not move. Saving a file that was not
+// modified returns the bytes it was parsed from.
+func SaveFilePreserving(file *LangFile, doXor bool) []byte {
+	var buf bytes.Buffer
+	err := EncodePreserving(&buf, file, doXor)
+	if err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+// EncodePreserving is the io.Writer counterpart of SaveFilePreserving.
+func EncodePreserving(w io.Writer, file *LangFile, doXor bool) error {
+	chunks := file.Chunks
+	if len(chunks) == 0 {
+		chunks = []chunk.Chunk{{ID: StringChunkID}, {ID: chunk.PaddingID}, {ID: CharMapChunkID}}
+	}
+
+	// only the first string and charmap chunks are rewritten
+	strIdx, cmIdx := -1, -1
+	for i, c := range chunks {
+		if c.ID == StringChunkID && strIdx < 0 {
+			strIdx = i
+		}
+		if c.ID == CharMapChunkID && cmIdx < 0 {
+			cmIdx = i
+		}
+	}
+
+	if doXor {
+		w = xor.NewWriter(w)
+	}
+	cw := chunk.NewWriter(w)
+	origOffset := int64(0)
+	for i, c := range chunks {
+		out := c
+		switch c.ID {
+		case StringChunkID:
+			if i == strIdx {
+				data, err := preserveStrings(file, c.Data)
+				if err != nil {
+					return err
+				}
+				out.Data = data
+			}
+		case CharMapChunkID:
+			if i == cmIdx {
+				out.Data = file.CharMap.ChunkData()
+				if len(c.Data) > len(out.Data) {
+					out.Data = append(out.Data, c.Data[len(out.Data):]...)
+				}
+			}
+		case chunk.PaddingID:
+			if cw.Offset() != origOffset {
+				out.Data = make([]byte, chunk.PaddingLen(cw.Offset(), 16))
+			}
+		}
+		origOffset += int64(len(c.Data)) + 8
+
+		if err := cw.WriteChunk(out); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// preserveStrings builds a string chunk payload that reuses the header and
+// string table of orig.
+func preserveStrings(file *LangFile, orig []byte) ([]byte, error) {
+	header := make([]byte, stringHeaderSize)
+	binary.LittleEndian.PutUint32(header[4:8], stringHeaderSize)
+	hasOrig := false
+	origName := ""
+	var origHashes []uint32
+	var blob []byte
+	gap := 0
+	used := 0
+
+	if len(orig) >= stringHeaderSize {
+		origCount := int(binary.LittleEndian.Uint32(orig[0:4]))
+		tableStart := int(binary.LittleEndian.Uint32(orig[4:8]))
+		stringsStart := int(binary.LittleEndian.Uint32(orig[8:12]))
+		if tableStart >= stringHeaderSize && tableStart+origCount*8 <= stringsStart && stringsStart <= len(orig) {
+			header = append([]byte(nil), orig[:tableStart]...)
+			hasOrig = true
+			origName = readName(orig)
+			gap = stringsStart - tableStart - origCount*8
+			blob = orig[stringsStart:]
+			origHashes = make([]uint32, origCount)
+			for i := 0; i < origCount; i++ {
+				at := tableStart + i*8
+				origHashes[i] = binary.LittleEndian.Uint32(orig[at : at+4])
+				location := int(binary.LittleEndian.Uint32(orig[at+4 : at+8]))
+				if location >= len(blob) {
+					continue
+				}
+				if end := bytes.IndexByte(blob[location:], 0); end >= 0 && location+end+1 > used {
+					used = location + end + 1
+				}
+			}
+		}
+	}
+
+	if !hasOrig || file.Name != origName {
+		if err := putName(header, file.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	encoded := make([]tEntry, len(file.Entries))
+	var appended []byte
+	for i := range file.Entries {
+		e := &file.Entries[i]
+		b, err := encodeEntry(file.CharMap, e, nil)
+		if err != nil {
+			return nil, err
+		}
+		if e.OriginalBytes != nil && bytes.Equal(b, e.OriginalBytes) && isStringAt(blob[:used], int(e.Offset), b) {
+			encoded[i] = tEntry{Hash: e.Hash, Offset: e.Offset}
+			continue
+		}
+		encoded[i] = tEntry{Hash: e.Hash, Offset: uint32(used + len(appended))}
+		appended = append(appended, b...)
+		appended = append(appended, 0)
+	}
+
+	// the hashes of the original table in their order, with the new ones
+	// added after them or at their position in a table sorted by hash
+	pending := make(map[uint32][]int, len(encoded))
+	for i, e := range encoded {
+		pending[e.Hash] = append(pending[e.Hash], i)
+	}
+	table := make([]tEntry, 0, len(encoded))
+	done := make([]bool, len(encoded))
+	for _, h := range origHashes {
+		if idx := pending[h]; len(idx) > 0 {
+			table = append(table, encoded[idx[0]])
+			done[idx[0]] = true
+			pending[h] = idx[1:]
+		}
+	}
+	added := make([]tEntry, 0)
+	for i, e := range encoded {
+		if !done[i] {
+			added = append(added, e)
+		}
+	}
+	table = insertEntries(table, added, sortedHashes(origHashes))
+
+	if len(appended) > 0 {
+		blob = append(append([]byte(nil), blob[:used]...), appended...)
+		// same alignment as SaveFile
+		langLen := 8 + len(header) + len(table)*8 + gap + len(blob)
+		blob = append(blob, make([]byte, 4-(langLen%4))...)
+	}
+
+	data := make([]byte, 0, len(header)+len(table)*8+gap+len(blob))
+	data = append(data, header...)
+	binary.LittleEndian.PutUint32(data[0:4], uint32(len(table)))
+	binary.LittleEndian.PutUint32(data[8:12], uint32(len(header)+len(table)*8+gap))
+	var ent [8]byte
+	for _, e := range table {
+		binary.LittleEndian.PutUint32(ent[0:4], e.Hash)
+		binary.LittleEndian.PutUint32(ent[4:8], e.Offset)
+		data = append(data, ent[:]...)
+	}
+	data = append(data, make([]byte, gap)...)
+	data = append(data, blob...)
+
+	return data, nil
+}
+
+type tEntry struct {
+	Hash   uint32
+	Offset uint32
+}
+
+// sortedHashes reports whether hashes are in ascending order.
+func sortedHashes(hashes []uint32) bool {
+	for i := 1; i < len(hashes); i++ {
+		if hashes[i] < hashes[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+// insertEntries adds the entries of added to table. If sorted is set, table is
+// sorted by hash and stays so, otherwise added is appended.
+func insertEntries(table []tEntry, added []tEntry, sorted bool) []tEntry {
+	if !sorted {
+		return append(table, added...)
+	}
+
+	sort.SliceStable(added, func(i, j int) bool { return added[i].Hash < added[j].Hash })
+	merged := make([]tEntry, 0, len(table)+len(added))
+	for len(table) > 0 && len(added) > 0 {
+		if added[0].Hash < table[0].Hash {
+			merged = append(merged, added[0])
+			added = added[1:]
+		} else {
+			merged = append(merged, table[0])
+			table = table[1:]
+		}
+	}
+	merged = append(merged, table...)
+	return append(merged, added...)
+}
+
+func isStringAt(blob []byte, offset int, b []byte) bool {
+	return offset+len(b) < len(blob) && bytes.Equal(blob[offset:offset+len(b)], b) && blob[offset+len(b)] == 0
+}
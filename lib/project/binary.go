@@ -0,0 +1,261 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package project
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/WorldUnitedNFS/worldlangedit/lib"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/charmap"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/format"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/hashdict"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// LoadLangFile reads a binary language file.
+func LoadLangFile(fp string) (*lib.LangFile, error) {
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return lib.Decode(f)
+}
+
+// SaveLangFile writes file XOR-encoded. lFile is the labels file used to order
+// the string table.
+func SaveLangFile(fp string, file *lib.LangFile, lFile *lib.LangFile) error {
+	f, err := os.Create(fp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	err = lib.Encode(w, file, lFile, true)
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// PackFromLangFile returns the text form of a binary language file, naming
+// the strings with dict. Strings dict has no label for are stored under their
+// hex key, the number of them is returned along with the pack.
+func PackFromLangFile(lf *lib.LangFile, dict *hashdict.Dict) (*format.Pack, int) {
+	p := format.NewPack()
+	p.Name = lf.Name
+
+	unresolved := 0
+	for _, e := range lf.Entries {
+		if _, ok := dict.Lookup(e.Hash); !ok {
+			unresolved++
+		}
+		p.Entries[dict.Resolve(e.Hash)] = e.String
+	}
+
+	for _, c := range lf.CharMap.EntryTable {
+		if c >= 0x80 {
+			p.SpecialChars = append(p.SpecialChars, string(rune(c)))
+		}
+	}
+
+	return p, unresolved
+}
+
+// BuildLangFile builds the binary form of a language pack, with a charmap
+// holding the special characters followed by every other character the
+// strings use.
+func BuildLangFile(p *format.Pack) (*lib.LangFile, error) {
+	if collisions := hashdict.Collisions(p.Labels()); len(collisions) > 0 {
+		return nil, collisions[0]
+	}
+
+	lp := buildEntries(p)
+	cm, err := charmap.Build(charmap.Runes(p.SpecialCharRunes(), p.Strings()))
+
+	if err != nil {
+		return nil, err
+	}
+
+	lp.CharMap = cm
+	return lp, nil
+}
+
+// buildEntries returns the binary form of a language pack without a charmap.
+func buildEntries(p *format.Pack) *lib.LangFile {
+	entries := make([]lib.LangFileEntry, 0)
+
+	// sorted, so building an unchanged pack gives the same file
+	for _, l := range p.Labels() {
+		entries = append(entries, lib.LangFileEntry{
+			Hash:          hashdict.Hash(l),
+			String:        p.Entries[l],
+			Offset:        0,
+			OriginalBytes: nil,
+		})
+	}
+
+	return &lib.LangFile{
+		Name:    p.Name,
+		Entries: entries,
+	}
+}
+
+// FindBinary returns the names of the pack families with a Labels binary file
+// in dir in ascending order.
+func FindBinary(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, LabelsLanguage+"_*.bin"))
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for _, fp := range files {
+		if _, name, ok := lib.SplitFileName(fp); ok && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// LoadOptions control how LoadBinary reads the binary files.
+type LoadOptions struct {
+	// Dict names the hashes missing from the Labels file, it may be nil.
+	Dict *hashdict.Dict
+	// SkipInvalid skips language files that can't be read instead of failing.
+	SkipInvalid bool
+	// Logf is called with every line of progress, it may be nil.
+	Logf func(format string, a ...interface{})
+}
+
+// LoadBinary reads the binary files of the pack family called name from dir.
+// Strings are named with the Labels file and the dict of opts, which may be
+// nil.
+func LoadBinary(dir string, name string, opts *LoadOptions) (*Project, error) {
+	if opts == nil {
+		opts = &LoadOptions{}
+	}
+	logf := logger(opts.Logf)
+
+	labelsPath := filepath.Join(dir, LabelsLanguage+"_"+name+".bin")
+	labelsFile, err := LoadLangFile(labelsPath)
+	if err != nil {
+		return nil, &os.PathError{Op: "read", Path: labelsPath, Err: err}
+	}
+
+	d := hashdict.New()
+	d.AddLangFile(labelsFile)
+	if opts.Dict != nil {
+		for _, l := range opts.Dict.Labels() {
+			d.Add(l)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*_"+name+".bin"))
+	if err != nil {
+		return nil, err
+	}
+
+	p := New(name)
+	for _, fp := range files {
+		lang, pack, ok := lib.SplitFileName(fp)
+		if !ok || pack != name {
+			continue
+		}
+
+		logf("Loading file: %s", fp)
+		lf := labelsFile
+		var err error
+		if lang != LabelsLanguage {
+			lf, err = LoadLangFile(fp)
+		}
+		if err != nil && opts.SkipInvalid {
+			logf("Skipping file %s: %v", fp, err)
+			continue
+		}
+		if err != nil {
+			return nil, &os.PathError{Op: "read", Path: fp, Err: err}
+		}
+		logf("Loaded %d strings from file", len(lf.Entries))
+
+		lp, unresolved := PackFromLangFile(lf, d)
+		// the name of the family is implied
+		if lp.Name == name {
+			lp.Name = ""
+		}
+		if unresolved > 0 {
+			logf("%d strings in %s have no label, they are stored under their hash", unresolved, fp)
+		}
+
+		if lang == LabelsLanguage {
+			p.Labels = lp
+		} else {
+			p.Languages[lang] = lp
+		}
+	}
+
+	return p, nil
+}
+
+// Row is a string of a language file joined with its label.
+type Row struct {
+	Hash        uint32
+	Label       string
+	Translation string
+}
+
+// Join returns a row for every hash in the labels file or the language file,
+// ordered by hash.
+func Join(labels *lib.LangFile, lang *lib.LangFile) []Row {
+	rows := make(map[uint32]*Row)
+	row := func(hash uint32) *Row {
+		r, exists := rows[hash]
+		if !exists {
+			r = &Row{Hash: hash}
+			rows[hash] = r
+		}
+		return r
+	}
+
+	for _, e := range labels.Entries {
+		row(e.Hash).Label = e.String
+	}
+	for _, e := range lang.Entries {
+		row(e.Hash).Translation = e.String
+	}
+
+	out := make([]Row, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Hash < out[j].Hash })
+	return out
+}
+
+// CheckNewLabel returns an error if label can't be added to the files because
+// it or its hash is already taken.
+func CheckNewLabel(labels *lib.LangFile, lang *lib.LangFile, label string) error {
+	hash := hashdict.Hash(label)
+	if le := labels.Get(hash); le != nil {
+		if le.String == label {
+			return fmt.Errorf("label %s already exists", label)
+		}
+		return fmt.Errorf("label %s collides with %s, both hash to %s", label, le.String, hashdict.HexKey(hash))
+	}
+	if lang.Has(hash) {
+		return fmt.Errorf("label %s collides with an unlabeled string, both hash to %s", label, hashdict.HexKey(hash))
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package project
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const csvLabelColumn = "Label"
+
+// csvCell returns the string to store for a cell in place of old. encoding/csv
+// turns \r\n inside quoted fields into \n, so the line endings of old are kept.
+func csvCell(cell string, old string) string {
+	if strings.Contains(old, "\r\n") && !strings.Contains(cell, "\r") {
+		return strings.ReplaceAll(cell, "\n", "\r\n")
+	}
+	return cell
+}
+
+// WriteCsv writes a spreadsheet of p with a row per label and a column per
+// language.
+func (p *Project) WriteCsv(w io.Writer, comma rune) error {
+	langs := p.EditableLanguages()
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+
+	if err := cw.Write(append([]string{csvLabelColumn}, langs...)); err != nil {
+		return err
+	}
+
+	for _, l := range p.Labels.Labels() {
+		row := make([]string, 0, len(langs)+1)
+		row = append(row, l)
+		for _, lang := range langs {
+			row = append(row, p.Languages[lang].Entries[l])
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// ApplyCsv applies the changed cells of a spreadsheet to the languages of p.
+// Empty cells are left alone. Unknown labels, languages and duplicate rows are
+// rejected before anything is changed. It returns the number of changed
+// strings of every language with a column.
+func (p *Project) ApplyCsv(r io.Reader, comma rune) (map[string]int, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = comma
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("spreadsheet is empty")
+	}
+
+	header := records[0]
+	header[0] = strings.TrimPrefix(header[0], "\uFEFF")
+
+	if header[0] != csvLabelColumn {
+		return nil, fmt.Errorf("first column must be %s, got %q", csvLabelColumn, header[0])
+	}
+
+	editable := make(map[string]bool)
+	for _, lang := range p.EditableLanguages() {
+		editable[lang] = true
+	}
+
+	seenLangs := make(map[string]bool)
+	for _, lang := range header[1:] {
+		if !editable[lang] {
+			return nil, fmt.Errorf("unknown language column %q", lang)
+		}
+		if seenLangs[lang] {
+			return nil, fmt.Errorf("duplicate language column %q", lang)
+		}
+		seenLangs[lang] = true
+	}
+
+	seenLabels := make(map[string]int)
+	for i, row := range records[1:] {
+		if _, exists := p.Labels.Entries[row[0]]; !exists {
+			return nil, fmt.Errorf("row %d: unknown label %q", i+2, row[0])
+		}
+		if prev, exists := seenLabels[row[0]]; exists {
+			return nil, fmt.Errorf("row %d: label %s already appears in row %d", i+2, row[0], prev)
+		}
+		seenLabels[row[0]] = i + 2
+	}
+
+	changes := make(map[string]int)
+	for _, lang := range header[1:] {
+		changes[lang] = 0
+	}
+
+	for _, row := range records[1:] {
+		for j, lang := range header[1:] {
+			entries := p.Languages[lang].Entries
+			cell := csvCell(row[j+1], entries[row[0]])
+			if cell == "" || entries[row[0]] == cell {
+				continue
+			}
+			entries[row[0]] = cell
+			changes[lang]++
+		}
+	}
+
+	return changes, nil
+}
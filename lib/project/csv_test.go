@@ -0,0 +1,119 @@
+package project_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/WorldUnitedNFS/worldlangedit/lib/format"
+)
+
+func TestWriteCsv(t *testing.T) {
+	p := testProject()
+	p.Languages["Largest"] = format.NewPack()
+	p.Languages["German"].Entries["TXT_HELLO"] = "Hallo,\r\nWelt"
+
+	var buf bytes.Buffer
+	if err := p.WriteCsv(&buf, ','); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Label,English,German\nTXT_CAR,Car,Wagen \u00FC\nTXT_HELLO,Hello,\"Hallo,\r\nWelt\"\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected spreadsheet: %q", buf.String())
+	}
+
+	changes, err := p.ApplyCsv(&buf, ',')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changes, map[string]int{"English": 0, "German": 0}) {
+		t.Errorf("Round trip changed strings: %v", changes)
+	}
+	if p.Languages["German"].Entries["TXT_HELLO"] != "Hallo,\r\nWelt" {
+		t.Errorf("Line endings lost: %q", p.Languages["German"].Entries["TXT_HELLO"])
+	}
+}
+
+func TestApplyCsv(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		want    map[string]string
+		changes map[string]int
+		err     bool
+	}{
+		{
+			name:    "update",
+			csv:     "\uFEFFLabel\tGerman\nTXT_HELLO\tServus\nTXT_CAR\t\n",
+			want:    map[string]string{"TXT_HELLO": "Servus"},
+			changes: map[string]int{"German": 1},
+		},
+		{
+			name:    "unchanged",
+			csv:     "Label\tEnglish\tGerman\nTXT_HELLO\tHello\tHallo\n",
+			changes: map[string]int{"English": 0, "German": 0},
+		},
+		{
+			name: "empty",
+			err:  true,
+		},
+		{
+			name: "no label column",
+			csv:  "German\tEnglish\nHallo\tHello\n",
+			err:  true,
+		},
+		{
+			name: "unknown language",
+			csv:  "Label\tFrench\nTXT_HELLO\tBonjour\n",
+			err:  true,
+		},
+		{
+			name: "largest",
+			csv:  "Label\tLargest\nTXT_HELLO\tHello\n",
+			err:  true,
+		},
+		{
+			name: "duplicate language",
+			csv:  "Label\tGerman\tGerman\nTXT_HELLO\tServus\tHallo\n",
+			err:  true,
+		},
+		{
+			name: "unknown label",
+			csv:  "Label\tGerman\nTXT_HELLO\tServus\nTXT_GONE\tWeg\n",
+			err:  true,
+		},
+		{
+			name: "duplicate label",
+			csv:  "Label\tGerman\nTXT_HELLO\tServus\nTXT_HELLO\tMoin\n",
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testProject()
+			p.Languages["Largest"] = format.NewPack()
+			want := map[string]string{"TXT_HELLO": "Hallo", "TXT_CAR": "Wagen \u00FC"}
+			for l, s := range tt.want {
+				want[l] = s
+			}
+
+			changes, err := p.ApplyCsv(strings.NewReader(tt.csv), '\t')
+			if tt.err {
+				if err == nil {
+					t.Error("applying the spreadsheet succeeded")
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(changes, tt.changes) {
+				t.Errorf("Unexpected changes: %v", changes)
+			}
+
+			if got := p.Languages["German"].Entries; !reflect.DeepEqual(got, want) {
+				t.Errorf("Unexpected German strings: %v", got)
+			}
+		})
+	}
+}
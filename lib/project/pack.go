@@ -0,0 +1,518 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package project
+
+import (
+	"fmt"
+	"github.com/WorldUnitedNFS/worldlangedit/lib"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/charmap"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/format"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/hashdict"
+	"golang.org/x/text/unicode/norm"
+	"path/filepath"
+	"sort"
+)
+
+// Layout is the order of the runes in the charmap of a pack.
+type Layout int
+
+const (
+	// LayoutFrequency gives the most used runes the single byte slots, except
+	// in packs with OrderedCharMap set. Used SpecialChars keep the first slots
+	// in their order. Ties go to the lower rune, so packing an unchanged pack
+	// gives the same charmap.
+	LayoutFrequency Layout = iota
+	// LayoutOrdered keeps the SpecialChars order.
+	LayoutOrdered
+)
+
+// PackOptions control how SaveBinary builds the binary files.
+type PackOptions struct {
+	Layout Layout
+	// Normalize is applied to labels, strings and special characters, nil
+	// leaves them alone.
+	Normalize *norm.Form
+	// Fallback substitutes the characters that can't be encoded, nil fails on
+	// them.
+	Fallback *charmap.Fallback
+	// Strict fails on missing and unknown strings and on strings longer than
+	// their Largest entry, which are otherwise only reported.
+	Strict bool
+	// Logf is called with every line of progress, it may be nil.
+	Logf func(format string, a ...interface{})
+}
+
+func logger(logf func(string, ...interface{})) func(string, ...interface{}) {
+	if logf == nil {
+		return func(string, ...interface{}) {}
+	}
+	return logf
+}
+
+// formName returns the name of a normalization form.
+func formName(form norm.Form) string {
+	switch form {
+	case norm.NFC:
+		return "nfc"
+	case norm.NFD:
+		return "nfd"
+	case norm.NFKC:
+		return "nfkc"
+	default:
+		return "nfkd"
+	}
+}
+
+// Normalize normalizes the labels, strings and special characters of lp in
+// place. It returns the sorted labels of the entries that changed.
+func Normalize(lp *format.Pack, form norm.Form) ([]string, error) {
+	entries := make(map[string]string, len(lp.Entries))
+	changed := make([]string, 0)
+
+	for l, e := range lp.Entries {
+		nl := form.String(l)
+		ne := form.String(e)
+
+		if _, exists := entries[nl]; exists {
+			return nil, fmt.Errorf("label %q collides with another label after normalization", l)
+		}
+
+		entries[nl] = ne
+
+		if nl != l || ne != e {
+			changed = append(changed, nl)
+		}
+	}
+
+	for i, c := range lp.SpecialChars {
+		lp.SpecialChars[i] = form.String(c)
+	}
+
+	lp.Entries = entries
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// SubstitutionReport lists the substitutions made in the string of a label.
+type SubstitutionReport struct {
+	Label         string
+	Substitutions []charmap.Substitution
+}
+
+// FallbackCharMap lays out a charmap for a pack that may use more characters
+// than the table holds: the special characters in their order, then the other
+// characters from the most to the least used. Characters that don't fit are
+// left out for the fallback to substitute.
+func FallbackCharMap(lp *format.Pack) (*charmap.Charmap, error) {
+	chars := charmap.Runes(lp.SpecialCharRunes(), nil)
+	pinned := make(map[rune]bool, len(chars))
+	for _, c := range chars {
+		pinned[c] = true
+	}
+
+	for _, c := range charmap.RunesByFrequency(nil, lp.Strings()) {
+		if !pinned[c] {
+			chars = append(chars, c)
+		}
+	}
+
+	if max := charmap.PlanCapacity(len(chars)).MaxRunes; len(chars) > max && len(pinned) <= max {
+		chars = chars[:max]
+	}
+
+	return charmap.Build(chars)
+}
+
+// ApplyFallback encodes the strings of lf with Charmap.EncodeFallback and
+// replaces them with what fb substituted, so they can be encoded with the
+// charmap of lf. labels names the entries and may be nil. It returns the
+// substitutions sorted by label.
+func ApplyFallback(lf *lib.LangFile, labels *lib.LangFile, fb *charmap.Fallback) ([]SubstitutionReport, error) {
+	reports := make([]SubstitutionReport, 0)
+	for i := range lf.Entries {
+		e := &lf.Entries[i]
+		label := hashdict.HexKey(e.Hash)
+		if labels != nil {
+			if le := labels.Get(e.Hash); le != nil {
+				label = le.String
+			}
+		}
+
+		b, subs, err := lf.CharMap.EncodeFallback(e.String, fb)
+
+		if ee, ok := err.(*charmap.EncodeError); ok {
+			ee.Label = label
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(subs) > 0 {
+			e.String = lf.CharMap.DecodeBytes(b)
+			reports = append(reports, SubstitutionReport{Label: label, Substitutions: subs})
+		}
+	}
+
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Label < reports[j].Label })
+	return reports, nil
+}
+
+// OptimizeCharMap replaces the charmap of lf with one ordered by character
+// frequency after the used runes of pinned and returns the number of bytes that saves over the old layout.
+func OptimizeCharMap(lf *lib.LangFile, pinned []rune) (int, error) {
+	strs := entryStrings(lf)
+	cm, err := charmap.Build(charmap.RunesByFrequency(pinned, strs))
+
+	if err != nil {
+		return 0, err
+	}
+
+	saved := lf.CharMap.EncodedSize(strs) - cm.EncodedSize(strs)
+	if saved < 0 {
+		return 0, nil
+	}
+
+	lf.CharMap = cm
+	return saved, nil
+}
+
+// MergeCharMaps builds one charmap covering every pack and pins its layout in
+// the SpecialChars of each of them. It returns the shared charmap.
+func MergeCharMaps(packs []*format.Pack) (*charmap.Charmap, error) {
+	pinned := make([][]rune, len(packs))
+	strs := make([][]string, len(packs))
+	for i, lp := range packs {
+		pinned[i] = lp.SpecialCharRunes()
+		strs[i] = lp.Strings()
+	}
+
+	chars, cm, err := charmap.Merge(pinned, strs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, lp := range packs {
+		specialChars := make([]string, len(chars))
+		for i, c := range chars {
+			specialChars[i] = string(c)
+		}
+		lp.SpecialChars = specialChars
+		lp.OrderedCharMap = true
+	}
+
+	return cm, nil
+}
+
+func entryStrings(lf *lib.LangFile) []string {
+	strs := make([]string, len(lf.Entries))
+	for i, e := range lf.Entries {
+		strs[i] = e.String
+	}
+	return strs
+}
+
+// packer builds the binary files of a project.
+type packer struct {
+	*PackOptions
+	logf func(string, ...interface{})
+}
+
+// normalize applies the normalization of the options to lp and reports what
+// it changed.
+func (b *packer) normalize(name string, lp *format.Pack) error {
+	if b.Normalize == nil {
+		return nil
+	}
+
+	before := len(charmap.Runes(lp.SpecialCharRunes(), lp.Strings()))
+	changed, err := Normalize(lp, *b.Normalize)
+
+	if err != nil {
+		return fmt.Errorf("failed to normalize %s: %v", name, err)
+	}
+
+	if len(changed) == 0 {
+		return nil
+	}
+
+	after := len(charmap.Runes(lp.SpecialCharRunes(), lp.Strings()))
+	b.logf("Normalized %d strings in %s to %s, saving %d charmap entries:", len(changed), name, formName(*b.Normalize), before-after)
+	for _, l := range changed {
+		b.logf("  %s", l)
+	}
+
+	return nil
+}
+
+// fallback substitutes the characters of lf that can't be encoded according
+// to the options and reports every substitution. A pack with more characters
+// than the table holds gets a charmap that leaves the least used ones out.
+func (b *packer) fallback(name string, lp *format.Pack, lf *lib.LangFile, labelsFile *lib.LangFile) error {
+	if b.Fallback == nil || b.Fallback.Policy == charmap.FallbackError {
+		return nil
+	}
+
+	if lf.CharMap == nil {
+		cm, err := FallbackCharMap(lp)
+
+		if err != nil {
+			return fmt.Errorf("failed to build %s: %v", name, err)
+		}
+
+		lf.CharMap = cm
+	}
+
+	reports, err := ApplyFallback(lf, labelsFile, b.Fallback)
+
+	if err != nil {
+		return fmt.Errorf("failed to substitute characters in %s: %v", name, err)
+	}
+
+	if len(reports) == 0 {
+		return nil
+	}
+
+	b.logf("Substituted characters in %d strings of %s:", len(reports), name)
+	for _, rep := range reports {
+		for _, sub := range rep.Substitutions {
+			b.logf("  %s: %c (%U) at position %d -> %q", rep.Label, sub.Rune, sub.Rune, sub.Pos, sub.Replacement)
+		}
+	}
+
+	return nil
+}
+
+// language builds the binary file of a language. It returns a nil file along
+// with the number of strings that can't be encoded.
+func (b *packer) language(name string, lp *format.Pack, labelsFile *lib.LangFile) (*lib.LangFile, int, error) {
+	if err := b.normalize(name, lp); err != nil {
+		return nil, 0, err
+	}
+
+	lf, err := BuildLangFile(lp)
+
+	// the fallback lays out a charmap of its own when the characters don't fit
+	if _, ok := err.(*charmap.TooManyRunesError); ok && b.Fallback != nil && b.Fallback.Policy != charmap.FallbackError {
+		lf, err = buildEntries(lp), nil
+	}
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to build %s: %v", name, err)
+	}
+
+	if err := b.fallback(name, lp, lf, labelsFile); err != nil {
+		return nil, 0, err
+	}
+
+	if errs := lib.ValidateStrings(lf, labelsFile); len(errs) > 0 {
+		for _, err := range errs {
+			b.logf("%s: %v", name, err)
+		}
+		return nil, len(errs), nil
+	}
+
+	if b.Layout == LayoutFrequency && !lp.OrderedCharMap {
+		saved, err := OptimizeCharMap(lf, lp.SpecialCharRunes())
+
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to build %s: %v", name, err)
+		}
+
+		b.logf("Frequency charmap layout saves %d bytes in %s", saved, name)
+	}
+
+	capacity := charmap.PlanCapacity(len(lf.CharMap.Entries()))
+	b.logf("Charmap of %s: %d characters (%d single byte, %d two byte), %d entries free",
+		name, capacity.Runes, capacity.Runes-capacity.TwoByteRunes, capacity.TwoByteRunes, capacity.Headroom)
+
+	if b.Strict {
+		for _, e := range labelsFile.Entries {
+			if lf.FindEntryByHash(e.Hash) == nil {
+				return nil, 0, fmt.Errorf("strict mode: pack %s does not have an entry for string %s", name, e.String)
+			}
+		}
+
+		for l := range lp.Entries {
+			if !labelsFile.Has(hashdict.Hash(l)) {
+				return nil, 0, fmt.Errorf("strict mode: pack %s has an entry for a nonexistent string (%s)", name, l)
+			}
+		}
+	}
+
+	b.logf("Loaded %d strings from %s", len(lp.Entries), name)
+	return lf, 0, nil
+}
+
+// checkLargest checks every language string against the Largest pack of the
+// project, which the game sizes its text buffers with.
+func (b *packer) checkLargest(name string, lp *format.Pack, names []string, files []*lib.LangFile, labelsFile *lib.LangFile) error {
+	if err := b.normalize(name, lp); err != nil {
+		return err
+	}
+
+	supplied, err := BuildLangFile(lp)
+
+	if err != nil {
+		return fmt.Errorf("failed to build %s: %v", name, err)
+	}
+
+	tooLong := 0
+	for i, lf := range files {
+		violations, err := lib.CheckLargest(supplied, lf)
+
+		if err != nil {
+			return fmt.Errorf("failed to check %s against %s: %v", names[i], name, err)
+		}
+
+		for _, v := range violations {
+			label := lib.HexKey(v.Hash)
+			if le := labelsFile.Get(v.Hash); le != nil {
+				label = le.String
+			}
+			b.logf("%s: string %s is %d bytes, Largest entry is %d bytes", names[i], label, v.Length, v.Largest)
+			tooLong++
+		}
+	}
+
+	if tooLong > 0 && b.Strict {
+		return fmt.Errorf("strict mode: %d strings exceed their Largest entry", tooLong)
+	}
+	if tooLong > 0 {
+		b.logf("Warning: %d strings exceed their Largest entry", tooLong)
+	}
+
+	return nil
+}
+
+// largest builds the Largest file of the language files. The Largest pack of
+// the project, if any, is checked and then replaced.
+func (b *packer) largest(name string, lp *format.Pack, names []string, files []*lib.LangFile, labelsFile *lib.LangFile) (*lib.LangFile, error) {
+	if lp != nil {
+		if err := b.checkLargest(name, lp, names, files, labelsFile); err != nil {
+			return nil, err
+		}
+	}
+
+	largest, overflow, err := lib.BuildLargest(files)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to build %s: %v", name, err)
+	}
+
+	if len(overflow) > 0 {
+		b.logf("Largest charmap is full, %d characters are replaced by a two byte character in Largest", len(overflow))
+	}
+
+	if lp != nil {
+		b.logf("Regenerated %s from %d languages", name, len(files))
+	} else {
+		b.logf("Generated %s from %d languages", name, len(files))
+	}
+
+	return largest, nil
+}
+
+// nameField returns the name field of the file built from lp, which may be
+// nil. Packs without a name of their own take the name of the family.
+func (p *Project) nameField(lp *format.Pack) string {
+	if lp == nil || lp.Name == "" {
+		return p.Name
+	}
+	return lp.Name
+}
+
+// SaveBinary builds the binary files of p and writes them to dir. The
+// Largest file is rebuilt from the other languages, after checking them
+// against the Largest pack of p. Normalization is applied to the packs of p in
+// place, substitutions only to the binary files.
+func (p *Project) SaveBinary(dir string, opts *PackOptions) error {
+	if opts == nil {
+		opts = &PackOptions{}
+	}
+	b := &packer{PackOptions: opts, logf: logger(opts.Logf)}
+
+	labelsName := LabelsLanguage + "_" + p.Name
+
+	if err := b.normalize(labelsName, p.Labels); err != nil {
+		return err
+	}
+
+	if collisions := hashdict.Collisions(p.Labels.Labels()); len(collisions) > 0 {
+		for _, c := range collisions {
+			b.logf("%s: %v", labelsName, c)
+		}
+		return fmt.Errorf("pack %s has %d hash collisions", p.Name, len(collisions))
+	}
+
+	labelsFile, err := BuildLangFile(p.Labels)
+
+	if err != nil {
+		return fmt.Errorf("failed to build %s: %v", labelsName, err)
+	}
+
+	labelsFile.Name = p.nameField(p.Labels)
+
+	names := make([]string, 0, len(p.Languages))
+	files := make([]*lib.LangFile, 0, len(p.Languages))
+	badStrings := 0
+
+	for _, lang := range p.LanguageNames() {
+		// the Largest file is rebuilt from the other languages below
+		if lang == LargestLanguage {
+			continue
+		}
+
+		name := lang + "_" + p.Name
+		lf, bad, err := b.language(name, p.Languages[lang], labelsFile)
+
+		if err != nil {
+			return err
+		}
+
+		if lf == nil {
+			badStrings += bad
+			continue
+		}
+
+		lf.Name = p.nameField(p.Languages[lang])
+		names = append(names, name)
+		files = append(files, lf)
+	}
+
+	if badStrings > 0 {
+		return fmt.Errorf("pack %s has %d strings that cannot be encoded", p.Name, badStrings)
+	}
+
+	if len(files) > 0 {
+		name := LargestLanguage + "_" + p.Name
+		largest, err := b.largest(name, p.Languages[LargestLanguage], names, files, labelsFile)
+
+		if err != nil {
+			return err
+		}
+
+		largest.Name = p.nameField(p.Languages[LargestLanguage])
+		names = append(names, name)
+		files = append(files, largest)
+	}
+
+	names = append([]string{labelsName}, names...)
+	files = append([]*lib.LangFile{labelsFile}, files...)
+
+	for i, lf := range files {
+		fp := filepath.Join(dir, names[i]+".bin")
+		b.logf("Saving %s to %s", names[i], fp)
+
+		if err := SaveLangFile(fp, lf, labelsFile); err != nil {
+			return err
+		}
+
+		b.logf("Saved %s to %s", names[i], fp)
+	}
+
+	return nil
+}
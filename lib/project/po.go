@@ -0,0 +1,133 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package project
+
+import (
+	"fmt"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/format"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/hashdict"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/po"
+)
+
+// ImportConflict is a translation that was not applied.
+type ImportConflict struct {
+	Label  string
+	Reason string
+}
+
+// PoImportResult counts what importing a PO file did.
+type PoImportResult struct {
+	Updated   int
+	Fuzzy     int
+	Obsolete  int
+	Conflicts []ImportConflict
+}
+
+// sourceText returns the msgid of a label. Labels without source text use the
+// label itself, as an empty msgid is reserved for the header.
+func sourceText(source *format.Pack, label string) string {
+	if s := source.Entries[label]; s != "" {
+		return s
+	}
+	return label
+}
+
+// ExportPo builds a PO file for the labels of p with msgids from the source
+// language and msgstrs from lang. An empty lang builds a template.
+func (p *Project) ExportPo(source string, lang string) (*po.File, error) {
+	sourcePack, err := p.Language(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var langPack *format.Pack
+	if lang != "" {
+		if langPack, err = p.Language(lang); err != nil {
+			return nil, err
+		}
+	}
+
+	f := &po.File{
+		Header: []po.HeaderField{
+			{Name: "Project-Id-Version", Value: p.Name},
+			{Name: "MIME-Version", Value: "1.0"},
+			{Name: "Content-Type", Value: "text/plain; charset=UTF-8"},
+			{Name: "Content-Transfer-Encoding", Value: "8bit"},
+		},
+		Entries: make([]*po.Entry, 0, len(p.Labels.Entries)),
+	}
+	if lang != "" {
+		f.Header = append(f.Header, po.HeaderField{Name: "Language", Value: lang})
+	}
+
+	for _, l := range p.Labels.Labels() {
+		e := &po.Entry{
+			ExtractedComments: []string{"hash " + hashdict.HexKey(hashdict.Hash(l))},
+			Context:           l,
+			ID:                sourceText(sourcePack, l),
+		}
+		if langPack != nil {
+			e.Str = langPack.Entries[l]
+		}
+		f.Entries = append(f.Entries, e)
+	}
+
+	return f, nil
+}
+
+// ImportPo applies the translations of f to lang. Fuzzy entries are skipped
+// unless fuzzy is set, and entries whose msgid no longer matches the source
+// language are conflicts unless force is set.
+func (p *Project) ImportPo(f *po.File, source string, lang string, fuzzy bool, force bool) (*PoImportResult, error) {
+	sourcePack, err := p.Language(source)
+	if err != nil {
+		return nil, err
+	}
+	langPack, err := p.Language(lang)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &PoImportResult{Conflicts: make([]ImportConflict, 0)}
+	applied := make(map[string]string)
+
+	for _, e := range f.Entries {
+		switch {
+		case e.Obsolete:
+			res.Obsolete++
+			continue
+		case e.Fuzzy() && !fuzzy:
+			res.Fuzzy++
+			continue
+		case e.Str == "":
+			continue
+		}
+
+		if _, exists := p.Labels.Entries[e.Context]; !exists {
+			res.Conflicts = append(res.Conflicts, ImportConflict{Label: e.Context, Reason: "unknown label"})
+			continue
+		}
+
+		if prev, exists := applied[e.Context]; exists {
+			if prev != e.Str {
+				res.Conflicts = append(res.Conflicts, ImportConflict{Label: e.Context, Reason: "translated more than once"})
+			}
+			continue
+		}
+
+		if src := sourceText(sourcePack, e.Context); src != e.ID && !force {
+			res.Conflicts = append(res.Conflicts, ImportConflict{Label: e.Context, Reason: fmt.Sprintf("source text changed from %q to %q", e.ID, src)})
+			continue
+		}
+
+		applied[e.Context] = e.Str
+		if langPack.Entries[e.Context] != e.Str {
+			langPack.Entries[e.Context] = e.Str
+			res.Updated++
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,136 @@
+package project_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/WorldUnitedNFS/worldlangedit/lib/po"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/project"
+)
+
+func TestExportPo(t *testing.T) {
+	p := testProject()
+
+	pot, err := p.ExportPo("English", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pot.Entries) != 2 || pot.Entries[0].Context != "TXT_CAR" || pot.Entries[0].ID != "Car" || pot.Entries[0].Str != "" {
+		t.Errorf("Unexpected template entries: %+v", pot.Entries)
+	}
+
+	p.Languages["English"].Entries["TXT_CAR"] = ""
+	f, err := p.ExportPo("English", "German")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := f.Entries[0]; e.ID != "TXT_CAR" || e.Str != "Wagen \u00FC" {
+		t.Errorf("Unexpected entry for an empty source: %+v", e)
+	}
+
+	if _, err := p.ExportPo("English", "French"); err == nil {
+		t.Error("exporting a missing language succeeded")
+	}
+}
+
+func TestImportPo(t *testing.T) {
+	tests := []struct {
+		name      string
+		entries   []*po.Entry
+		fuzzy     bool
+		force     bool
+		want      map[string]string
+		result    project.PoImportResult
+		conflicts []string
+	}{
+		{
+			name:    "update",
+			entries: []*po.Entry{{Context: "TXT_HELLO", ID: "Hello", Str: "Servus"}, {Context: "TXT_CAR", ID: "Car", Str: "Wagen \u00FC"}},
+			want:    map[string]string{"TXT_HELLO": "Servus"},
+			result:  project.PoImportResult{Updated: 1},
+		},
+		{
+			name:    "empty msgstr",
+			entries: []*po.Entry{{Context: "TXT_HELLO", ID: "Hello"}},
+		},
+		{
+			name:    "fuzzy skipped",
+			entries: []*po.Entry{{Context: "TXT_HELLO", ID: "Hello", Str: "Servus", Flags: []string{"fuzzy"}}},
+			result:  project.PoImportResult{Fuzzy: 1},
+		},
+		{
+			name:    "fuzzy applied",
+			entries: []*po.Entry{{Context: "TXT_HELLO", ID: "Hello", Str: "Servus", Flags: []string{"fuzzy"}}},
+			fuzzy:   true,
+			want:    map[string]string{"TXT_HELLO": "Servus"},
+			result:  project.PoImportResult{Updated: 1},
+		},
+		{
+			name:    "obsolete",
+			entries: []*po.Entry{{Context: "TXT_HELLO", ID: "Hello", Str: "Servus", Obsolete: true}},
+			result:  project.PoImportResult{Obsolete: 1},
+		},
+		{
+			name:      "unknown label",
+			entries:   []*po.Entry{{Context: "TXT_GONE", ID: "Gone", Str: "Weg"}},
+			conflicts: []string{"TXT_GONE"},
+		},
+		{
+			name:      "source changed",
+			entries:   []*po.Entry{{Context: "TXT_HELLO", ID: "Hi", Str: "Servus"}},
+			conflicts: []string{"TXT_HELLO"},
+		},
+		{
+			name:    "source changed with force",
+			entries: []*po.Entry{{Context: "TXT_HELLO", ID: "Hi", Str: "Servus"}},
+			force:   true,
+			want:    map[string]string{"TXT_HELLO": "Servus"},
+			result:  project.PoImportResult{Updated: 1},
+		},
+		{
+			name:    "same duplicate",
+			entries: []*po.Entry{{Context: "TXT_HELLO", ID: "Hello", Str: "Servus"}, {Context: "TXT_HELLO", ID: "Hello", Str: "Servus"}},
+			want:    map[string]string{"TXT_HELLO": "Servus"},
+			result:  project.PoImportResult{Updated: 1},
+		},
+		{
+			name:      "conflicting duplicate",
+			entries:   []*po.Entry{{Context: "TXT_HELLO", ID: "Hello", Str: "Servus"}, {Context: "TXT_HELLO", ID: "Hello", Str: "Moin"}},
+			want:      map[string]string{"TXT_HELLO": "Servus"},
+			result:    project.PoImportResult{Updated: 1},
+			conflicts: []string{"TXT_HELLO"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testProject()
+			want := map[string]string{"TXT_HELLO": "Hallo", "TXT_CAR": "Wagen \u00FC"}
+			for l, s := range tt.want {
+				want[l] = s
+			}
+
+			res, err := p.ImportPo(&po.File{Entries: tt.entries}, "English", "German", tt.fuzzy, tt.force)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.Updated != tt.result.Updated || res.Fuzzy != tt.result.Fuzzy || res.Obsolete != tt.result.Obsolete {
+				t.Errorf("Unexpected result: %+v", res)
+			}
+			conflicts := make([]string, 0)
+			for _, c := range res.Conflicts {
+				conflicts = append(conflicts, c.Label)
+			}
+			if tt.conflicts == nil {
+				tt.conflicts = []string{}
+			}
+			if !reflect.DeepEqual(conflicts, tt.conflicts) {
+				t.Errorf("Unexpected conflicts: %+v", res.Conflicts)
+			}
+			if got := p.Languages["German"].Entries; !reflect.DeepEqual(got, want) {
+				t.Errorf("Unexpected German strings: %v", got)
+			}
+		})
+	}
+}
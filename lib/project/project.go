@@ -0,0 +1,166 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package project holds a pack family as a whole: its labels and the strings
+// of every language, in text and binary form.
+package project
+
+import (
+	"fmt"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/format"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/hashdict"
+	"sort"
+)
+
+const (
+	LabelsLanguage  = format.LabelsLanguage
+	LargestLanguage = "Largest"
+)
+
+// Project is a pack family, such as Global.
+type Project struct {
+	Name   string
+	Labels *format.Pack
+	// Languages holds the language packs by language, without the Labels pack.
+	Languages map[string]*format.Pack
+}
+
+func New(name string) *Project {
+	return &Project{
+		Name:      name,
+		Labels:    format.NewPack(),
+		Languages: make(map[string]*format.Pack),
+	}
+}
+
+// FromText returns the project of a text project, which must have a Labels
+// pack.
+func FromText(tp *format.Project) (*Project, error) {
+	labels, exists := tp.Languages[LabelsLanguage]
+	if !exists {
+		return nil, fmt.Errorf("pack %s has no %s_%s", tp.Name, LabelsLanguage, tp.Name)
+	}
+
+	p := New(tp.Name)
+	p.Labels = labels
+	for lang, lp := range tp.Languages {
+		if lang != LabelsLanguage {
+			p.Languages[lang] = lp
+		}
+	}
+	return p, nil
+}
+
+// Text returns the text form of p.
+func (p *Project) Text() *format.Project {
+	tp := &format.Project{
+		Name:      p.Name,
+		Languages: make(map[string]*format.Pack, len(p.Languages)+1),
+	}
+	tp.Languages[LabelsLanguage] = p.Labels
+	for lang, lp := range p.Languages {
+		tp.Languages[lang] = lp
+	}
+	return tp
+}
+
+// LoadText reads the project called name from dir.
+func LoadText(dir string, name string, c format.Codec) (*Project, error) {
+	tp, err := format.ReadProject(dir, name, c)
+	if err != nil {
+		return nil, err
+	}
+	return FromText(tp)
+}
+
+// SaveText writes p to dir.
+func (p *Project) SaveText(dir string, c format.Codec) error {
+	return format.WriteProject(dir, p.Text(), c)
+}
+
+// SaveLanguages writes the packs of langs to dir, leaving the files of the
+// other languages alone unless c stores the whole project in one file.
+func (p *Project) SaveLanguages(dir string, c format.Codec, langs ...string) error {
+	return format.WriteLanguages(dir, p.Text(), c, langs)
+}
+
+// LanguageNames returns the languages of p in ascending order.
+func (p *Project) LanguageNames() []string {
+	names := make([]string, 0, len(p.Languages))
+	for l := range p.Languages {
+		names = append(names, l)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EditableLanguages returns the languages of p in ascending order, without the
+// Largest pack, which SaveBinary generates from the others.
+func (p *Project) EditableLanguages() []string {
+	names := make([]string, 0, len(p.Languages))
+	for _, l := range p.LanguageNames() {
+		if l != LargestLanguage {
+			names = append(names, l)
+		}
+	}
+	return names
+}
+
+// Language returns the pack of lang.
+func (p *Project) Language(lang string) (*format.Pack, error) {
+	lp, exists := p.Languages[lang]
+	if !exists {
+		return nil, fmt.Errorf("pack %s has no %s_%s", p.Name, lang, p.Name)
+	}
+	return lp, nil
+}
+
+// AddString adds a label and its text in every language but the generated
+// Largest pack. Nothing is changed if the label or its hash is taken,
+// including by an unlabeled string stored under its hex key.
+func (p *Project) AddString(label string, text string) error {
+	if k, ok := hashdict.Collides(p.Labels.Labels(), label); ok {
+		return fmt.Errorf("label %s collides with %s, both hash to %s", label, k, hashdict.HexKey(hashdict.Hash(k)))
+	}
+	for _, lang := range p.LanguageNames() {
+		if k, ok := hashdict.Collides(p.Languages[lang].Labels(), label); ok {
+			return fmt.Errorf("label %s collides with %s in %s_%s, both hash to %s", label, k, lang, p.Name, hashdict.HexKey(hashdict.Hash(k)))
+		}
+	}
+	if _, exists := p.Labels.Entries[label]; exists {
+		return fmt.Errorf("string %s already exists in %s_%s", label, LabelsLanguage, p.Name)
+	}
+	for _, lang := range p.EditableLanguages() {
+		if _, exists := p.Languages[lang].Entries[label]; exists {
+			return fmt.Errorf("string %s already exists in %s_%s", label, lang, p.Name)
+		}
+	}
+
+	p.Labels.Entries[label] = label
+	for _, lang := range p.EditableLanguages() {
+		p.Languages[lang].Entries[label] = text
+	}
+	return nil
+}
+
+// RemoveString removes a label and its text in every language. Nothing is
+// changed if an editable language doesn't have the string.
+func (p *Project) RemoveString(label string) error {
+	if _, exists := p.Labels.Entries[label]; !exists {
+		return fmt.Errorf("string %s does not exist in %s_%s", label, LabelsLanguage, p.Name)
+	}
+	for _, lang := range p.EditableLanguages() {
+		if _, exists := p.Languages[lang].Entries[label]; !exists {
+			return fmt.Errorf("string %s does not exist in %s_%s", label, lang, p.Name)
+		}
+	}
+
+	delete(p.Labels.Entries, label)
+	delete(p.Labels.Notes, label)
+	for _, lp := range p.Languages {
+		delete(lp.Entries, label)
+		delete(lp.Notes, label)
+	}
+	return nil
+}
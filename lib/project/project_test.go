@@ -0,0 +1,508 @@
+package project_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/WorldUnitedNFS/worldlangedit/lib"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/charmap"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/chunk"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/format"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/hashdict"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/project"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/xor"
+	"golang.org/x/text/unicode/norm"
+)
+
+func testProject() *project.Project {
+	p := project.New("Global")
+	p.Labels.Entries["TXT_HELLO"] = "TXT_HELLO"
+	p.Labels.Entries["TXT_CAR"] = "TXT_CAR"
+
+	english := format.NewPack()
+	english.Entries["TXT_HELLO"] = "Hello"
+	english.Entries["TXT_CAR"] = "Car"
+	p.Languages["English"] = english
+
+	german := format.NewPack()
+	german.Entries["TXT_HELLO"] = "Hallo"
+	german.Entries["TXT_CAR"] = "Wagen \u00FC"
+	german.SpecialChars = []string{"\u00FC"}
+	p.Languages["German"] = german
+
+	return p
+}
+
+func TestAddRemoveString(t *testing.T) {
+	p := testProject()
+
+	if err := p.AddString("TXT_NEW", "New"); err != nil {
+		t.Fatal(err)
+	}
+	for _, lang := range p.LanguageNames() {
+		if p.Languages[lang].Entries["TXT_NEW"] != "New" {
+			t.Errorf("%s is missing TXT_NEW", lang)
+		}
+	}
+	if p.Labels.Entries["TXT_NEW"] != "TXT_NEW" {
+		t.Error("Labels is missing TXT_NEW")
+	}
+
+	if err := p.AddString("TXT_NEW", "New"); err == nil {
+		t.Error("adding an existing label succeeded")
+	}
+
+	p.Languages["German"].Entries[hashdict.HexKey(lib.BinHash("TXT_ORPHAN"))] = "Waise"
+	if err := p.AddString("TXT_ORPHAN", "Orphan"); err == nil {
+		t.Error("adding the label of an unlabeled string succeeded")
+	}
+	if _, exists := p.Labels.Entries["TXT_ORPHAN"]; exists {
+		t.Error("failed add changed Labels")
+	}
+
+	delete(p.Languages["German"].Entries, "TXT_CAR")
+	if err := p.RemoveString("TXT_CAR"); err == nil {
+		t.Error("removing a string missing from German succeeded")
+	}
+	if _, exists := p.Labels.Entries["TXT_CAR"]; !exists {
+		t.Error("failed removal changed Labels")
+	}
+
+	if err := p.RemoveString("TXT_NEW"); err != nil {
+		t.Fatal(err)
+	}
+	for _, lang := range p.LanguageNames() {
+		if _, exists := p.Languages[lang].Entries["TXT_NEW"]; exists {
+			t.Errorf("%s still has TXT_NEW", lang)
+		}
+	}
+}
+
+func TestAddStringSkipsLargest(t *testing.T) {
+	p := testProject()
+	largest := format.NewPack()
+	largest.Entries["TXT_HELLO"] = "Hallo"
+	largest.Entries["TXT_CAR"] = "Wagen \u00FC"
+	p.Languages[project.LargestLanguage] = largest
+
+	if err := p.AddString("TXT_NEW", "New"); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := largest.Entries["TXT_NEW"]; exists {
+		t.Error("Largest got the new string")
+	}
+	if p.Languages["German"].Entries["TXT_NEW"] != "New" {
+		t.Error("German is missing TXT_NEW")
+	}
+
+	if err := p.RemoveString("TXT_NEW"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.RemoveString("TXT_CAR"); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := largest.Entries["TXT_CAR"]; exists {
+		t.Error("Largest kept a removed string")
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := testProject()
+	p.Languages["German"].Name = "Career"
+	if err := p.SaveBinary(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "crack_state.bin"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := project.FindBinary(dir)
+	if err != nil || !reflect.DeepEqual(names, []string{"Global"}) {
+		t.Errorf("families = %v, %v, want Global", names, err)
+	}
+
+	loaded, err := project.LoadBinary(dir, "Global", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(loaded.Labels.Entries, p.Labels.Entries) {
+		t.Errorf("Labels = %v, want %v", loaded.Labels.Entries, p.Labels.Entries)
+	}
+	if got, want := loaded.LanguageNames(), []string{"English", "German", project.LargestLanguage}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("languages = %v, want %v", got, want)
+	}
+	for _, lang := range p.LanguageNames() {
+		if !reflect.DeepEqual(loaded.Languages[lang].Entries, p.Languages[lang].Entries) {
+			t.Errorf("%s = %v, want %v", lang, loaded.Languages[lang].Entries, p.Languages[lang].Entries)
+		}
+	}
+	if loaded.Languages["German"].Name != "Career" || loaded.Languages["English"].Name != "" {
+		t.Errorf("names = %q and %q, want Career and none", loaded.Languages["German"].Name, loaded.Languages["English"].Name)
+	}
+	if loaded.Languages[project.LargestLanguage].Entries["TXT_CAR"] != "Wagen \u00FC" {
+		t.Errorf("Largest TXT_CAR = %q", loaded.Languages[project.LargestLanguage].Entries["TXT_CAR"])
+	}
+}
+
+func TestLoadBinarySkipInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := testProject()
+	if err := p.SaveBinary(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	// sorts before Labels_Global.bin, so Labels is read after the skipped file
+	if err := ioutil.WriteFile(filepath.Join(dir, "Korean_Global.bin"), []byte("not a language pack"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := project.LoadBinary(dir, "Global", nil); err == nil {
+		t.Error("loading a corrupt file succeeded")
+	}
+
+	loaded, err := project.LoadBinary(dir, "Global", &project.LoadOptions{SkipInvalid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Labels.Entries, p.Labels.Entries) {
+		t.Errorf("Labels = %v, want %v", loaded.Labels.Entries, p.Labels.Entries)
+	}
+	if _, exists := loaded.Languages["Korean"]; exists {
+		t.Error("corrupt Korean file was loaded")
+	}
+	if !reflect.DeepEqual(loaded.Languages["English"].Entries, p.Languages["English"].Entries) {
+		t.Errorf("English = %v, want %v", loaded.Languages["English"].Entries, p.Languages["English"].Entries)
+	}
+}
+
+func TestJoin(t *testing.T) {
+	p := testProject()
+	p.Languages["English"].Entries["TXT_UNLABELED"] = "Orphan"
+
+	labels, err := project.BuildLangFile(p.Labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	english, err := project.BuildLangFile(p.Languages["English"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := project.Join(labels, english)
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+	for i := 1; i < len(rows); i++ {
+		if rows[i-1].Hash >= rows[i].Hash {
+			t.Errorf("rows not ordered by hash: %v", rows)
+		}
+	}
+	for _, r := range rows {
+		if r.Label == "" && r.Translation != "Orphan" {
+			t.Errorf("unexpected unlabeled row %v", r)
+		}
+		if r.Label == "TXT_HELLO" && r.Translation != "Hello" {
+			t.Errorf("TXT_HELLO row = %v", r)
+		}
+	}
+
+	if err := project.CheckNewLabel(labels, english, "TXT_HELLO"); err == nil {
+		t.Error("existing label accepted")
+	}
+	if err := project.CheckNewLabel(labels, english, "TXT_UNLABELED"); err == nil {
+		t.Error("label of an unlabeled string accepted")
+	}
+	if err := project.CheckNewLabel(labels, english, "TXT_FRESH"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSaveBinaryOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := testProject()
+	delete(p.Languages["English"].Entries, "TXT_CAR")
+	if err := p.SaveBinary(dir, &project.PackOptions{Strict: true}); err == nil {
+		t.Error("strict pack with a missing string succeeded")
+	}
+
+	p = testProject()
+	p.Languages["German"].Entries["TXT_CAR"] = "Wagen u\u0308"
+	p.Languages["German"].SpecialChars = nil
+	nfc := norm.NFC
+	if err := p.SaveBinary(dir, &project.PackOptions{Normalize: &nfc, Strict: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := project.LoadBinary(dir, "Global", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Languages["German"].Entries["TXT_CAR"]; got != "Wagen \u00FC" {
+		t.Errorf("German TXT_CAR = %q, want the NFC form", got)
+	}
+}
+
+func TestSaveBinaryStable(t *testing.T) {
+	dirs := make([]string, 2)
+	for i := range dirs {
+		dir, err := ioutil.TempDir("", "project")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		dirs[i] = dir
+
+		if err := testProject().SaveBinary(dir, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, fn := range []string{"Labels_Global.bin", "English_Global.bin", "German_Global.bin", "Largest_Global.bin"} {
+		a, err := ioutil.ReadFile(filepath.Join(dirs[0], fn))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dirs[1], fn))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(a, b) {
+			t.Errorf("%s differs between two packs of the same project", fn)
+		}
+	}
+}
+
+func TestSaveBinaryLargest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := testProject()
+	largest := format.NewPack()
+	largest.Entries["TXT_HELLO"] = "Hallo"
+	largest.Entries["TXT_CAR"] = "Car"
+	p.Languages[project.LargestLanguage] = largest
+
+	if err := p.SaveBinary(dir, &project.PackOptions{Strict: true}); err == nil {
+		t.Error("strict pack with a stale Largest succeeded")
+	}
+
+	var log []string
+	logf := func(format string, a ...interface{}) { log = append(log, fmt.Sprintf(format, a...)) }
+	if err := p.SaveBinary(dir, &project.PackOptions{Logf: logf}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"German_Global: string TXT_CAR is 7 bytes, Largest entry is 3 bytes",
+		"Regenerated Largest_Global from 2 languages",
+	}
+	for _, w := range want {
+		found := false
+		for _, l := range log {
+			found = found || l == w
+		}
+		if !found {
+			t.Errorf("log is missing %q: %q", w, log)
+		}
+	}
+
+	loaded, err := project.LoadBinary(dir, "Global", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Languages[project.LargestLanguage].Entries["TXT_CAR"]; got != "Wagen \u00FC" {
+		t.Errorf("Largest TXT_CAR = %q", got)
+	}
+}
+
+func TestSaveBinaryDefaultLayout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the last rune in ordered layout is the most used one, it takes two
+	// bytes in all 11 of its uses
+	runes := make([]rune, 200)
+	for i := range runes {
+		runes[i] = rune(0x4E00 + i)
+	}
+	p := testProject()
+	p.Languages["German"].Entries["TXT_CAR"] = string(runes) + strings.Repeat(string(runes[199]), 10)
+
+	var log []string
+	logf := func(format string, a ...interface{}) { log = append(log, fmt.Sprintf(format, a...)) }
+	if err := p.SaveBinary(dir, &project.PackOptions{Logf: logf}); err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, l := range log {
+		found = found || l == "Frequency charmap layout saves 11 bytes in German_Global"
+	}
+	if !found {
+		t.Errorf("default options did not use the frequency layout: %q", log)
+	}
+}
+
+func TestSaveBinaryPinnedSpecialChars(t *testing.T) {
+	dir, err := ioutil.TempDir("", "project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := testProject()
+	german := p.Languages["German"]
+	german.Entries["TXT_CAR"] = "\u00E9 \u00FC\u00FC\u00FC \u00F6\u00F6"
+	german.SpecialChars = []string{"\u00E9", "\u00FC"}
+	if err := p.SaveBinary(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := project.LoadBinary(dir, "Global", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"\u00E9", "\u00FC", "\u00F6"}
+	if got := loaded.Languages["German"].SpecialChars; !reflect.DeepEqual(got, want) {
+		t.Errorf("special chars = %q, want %q", got, want)
+	}
+}
+
+// charMapChunk returns the payload of the charmap chunk of a binary file.
+func charMapChunk(t *testing.T, fp string) []byte {
+	data, err := ioutil.ReadFile(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks, err := chunk.ReadAll(bytes.NewReader(xor.Decode(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range chunks {
+		if c.ID == lib.CharMapChunkID {
+			return c.Data
+		}
+	}
+
+	t.Fatalf("%s has no charmap chunk", fp)
+	return nil
+}
+
+func TestMergedCharMapPack(t *testing.T) {
+	dir, err := ioutil.TempDir("", "project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := testProject()
+	german := p.Languages["German"]
+	german.Entries["TXT_HELLO"] = "Hall\u00F6 \u00F6\u00F6"
+	french := format.NewPack()
+	french.Entries["TXT_HELLO"] = "All\u00F4 \u00E9\u00E9\u00E9"
+	french.Entries["TXT_CAR"] = "Voiture \u00FC"
+	p.Languages["French"] = french
+
+	if _, err := project.MergeCharMaps([]*format.Pack{german, french}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SaveBinary(dir, &project.PackOptions{Layout: project.LayoutFrequency}); err != nil {
+		t.Fatal(err)
+	}
+
+	germanChunk := charMapChunk(t, filepath.Join(dir, "German_Global.bin"))
+	frenchChunk := charMapChunk(t, filepath.Join(dir, "French_Global.bin"))
+	if !bytes.Equal(germanChunk, frenchChunk) {
+		t.Error("German and French charmaps differ after packing")
+	}
+}
+
+func TestSaveBinaryFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// more characters than a charmap holds, each used once, after the pinned
+	// characters of a merged layout
+	many := make([]rune, charmap.TableSize)
+	for i := range many {
+		many[i] = rune(0x4E00 + i)
+	}
+	p := testProject()
+	german := p.Languages["German"]
+	german.Entries["TXT_HELLO"] = "Hall\u00F6 \u2026"
+	german.Entries["TXT_CAR"] = "Wagen \u00FC " + string(many)
+	german.SpecialChars = []string{"\u00FC", "\u00F6"}
+	german.OrderedCharMap = true
+
+	if err := p.SaveBinary(dir, nil); err == nil {
+		t.Error("packing too many characters without a fallback succeeded")
+	}
+
+	var log []string
+	logf := func(format string, a ...interface{}) { log = append(log, fmt.Sprintf(format, a...)) }
+	opts := &project.PackOptions{
+		Fallback: &charmap.Fallback{Policy: charmap.FallbackTransliterate},
+		Logf:     logf,
+	}
+	if err := p.SaveBinary(dir, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	cm, err := charmap.ParseChunk(charMapChunk(t, filepath.Join(dir, "German_Global.bin")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries := cm.Entries(); entries[0].Rune != '\u00FC' || entries[1].Rune != '\u00F6' {
+		t.Errorf("special characters are not first in the charmap: %c %c", entries[0].Rune, entries[1].Rune)
+	}
+
+	loaded, err := project.LoadBinary(dir, "Global", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Languages["German"].Entries["TXT_HELLO"]; got != "Hall\u00F6 \u2026" {
+		t.Errorf("German TXT_HELLO = %q, want it unchanged", got)
+	}
+	if got := loaded.Languages["German"].Entries["TXT_CAR"]; !strings.HasPrefix(got, "Wagen \u00FC ") || !strings.HasSuffix(got, "?") {
+		t.Errorf("German TXT_CAR = %q, want the characters that don't fit replaced", got)
+	}
+
+	found := false
+	for _, l := range log {
+		found = found || l == "Substituted characters in 1 strings of German_Global:"
+	}
+	if !found {
+		t.Errorf("log is missing the substitution report: %q", log)
+	}
+}
@@ -0,0 +1,109 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package project
+
+import (
+	"fmt"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/format"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/hashdict"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/xliff"
+)
+
+// XliffImportResult counts what importing an XLIFF document did.
+type XliffImportResult struct {
+	Updated    int
+	Notes      int
+	Unapproved int
+	Conflicts  []ImportConflict
+}
+
+// ExportXliff builds an XLIFF document translating the labels of p from the
+// source language into lang. The notes of lang become the unit notes. A lang
+// p does not have yet gets empty targets.
+func (p *Project) ExportXliff(source string, lang string) (*xliff.Document, error) {
+	sourcePack, err := p.Language(source)
+	if err != nil {
+		return nil, err
+	}
+
+	langPack, exists := p.Languages[lang]
+	if !exists {
+		langPack = format.NewPack()
+	}
+
+	doc := &xliff.Document{
+		Original:       p.Name,
+		SourceLanguage: source,
+		TargetLanguage: lang,
+		Units:          make([]xliff.Unit, 0, len(p.Labels.Entries)),
+	}
+
+	for _, l := range p.Labels.Labels() {
+		doc.Units = append(doc.Units, xliff.Unit{
+			ID:     l,
+			Hash:   hashdict.Hash(l),
+			Source: sourcePack.Entries[l],
+			Target: langPack.Entries[l],
+			Notes:  langPack.Notes[l],
+		})
+	}
+
+	return doc, nil
+}
+
+// ImportXliff applies the approved translations of doc to lang. Units with
+// notes replace the notes of their label, whether or not they are approved;
+// units without notes leave them alone.
+func (p *Project) ImportXliff(doc *xliff.Document, source string, lang string, unapproved bool, force bool) (*XliffImportResult, error) {
+	sourcePack, err := p.Language(source)
+	if err != nil {
+		return nil, err
+	}
+	langPack, err := p.Language(lang)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &XliffImportResult{Conflicts: make([]ImportConflict, 0)}
+
+	for _, u := range doc.Units {
+		if _, exists := p.Labels.Entries[u.ID]; !exists {
+			if u.Target != "" || len(u.Notes) > 0 {
+				res.Conflicts = append(res.Conflicts, ImportConflict{Label: u.ID, Reason: "unknown label"})
+			}
+			continue
+		}
+
+		if h := hashdict.Hash(u.ID); u.Hash != 0 && u.Hash != h {
+			res.Conflicts = append(res.Conflicts, ImportConflict{Label: u.ID, Reason: fmt.Sprintf("hash %s does not match the label hash %s", hashdict.HexKey(u.Hash), hashdict.HexKey(h))})
+			continue
+		}
+
+		if len(u.Notes) > 0 && langPack.SetNotes(u.ID, u.Notes) {
+			res.Notes++
+		}
+
+		if u.Target == "" {
+			continue
+		}
+
+		if !u.Approved && !unapproved {
+			res.Unapproved++
+			continue
+		}
+
+		if src := sourcePack.Entries[u.ID]; src != u.Source && !force {
+			res.Conflicts = append(res.Conflicts, ImportConflict{Label: u.ID, Reason: fmt.Sprintf("source text changed from %q to %q", u.Source, src)})
+			continue
+		}
+
+		if langPack.Entries[u.ID] != u.Target {
+			langPack.Entries[u.ID] = u.Target
+			res.Updated++
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,137 @@
+package project_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/WorldUnitedNFS/worldlangedit/lib/hashdict"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/xliff"
+)
+
+func TestExportXliff(t *testing.T) {
+	p := testProject()
+	p.Languages["German"].Notes = map[string][]string{"TXT_CAR": {"Keep it short"}}
+
+	doc, err := p.ExportXliff("English", "German")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Original != "Global" || len(doc.Units) != 2 {
+		t.Fatalf("Unexpected document: %+v", doc)
+	}
+	if u := doc.Units[0]; u.ID != "TXT_CAR" || u.Source != "Car" || u.Target != "Wagen \u00FC" || !reflect.DeepEqual(u.Notes, []string{"Keep it short"}) {
+		t.Errorf("Unexpected unit: %+v", u)
+	}
+
+	doc, err = p.ExportXliff("English", "French")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Units[0].Target != "" {
+		t.Errorf("Unexpected target for a new language: %+v", doc.Units[0])
+	}
+}
+
+func TestImportXliff(t *testing.T) {
+	tests := []struct {
+		name       string
+		units      []xliff.Unit
+		unapproved bool
+		force      bool
+		want       map[string]string
+		notes      map[string][]string
+		updated    int
+		skipped    int
+		conflicts  []string
+	}{
+		{
+			name:    "approved",
+			units:   []xliff.Unit{{ID: "TXT_HELLO", Source: "Hello", Target: "Servus", Approved: true}},
+			want:    map[string]string{"TXT_HELLO": "Servus"},
+			updated: 1,
+		},
+		{
+			name:    "unapproved skipped",
+			units:   []xliff.Unit{{ID: "TXT_HELLO", Source: "Hello", Target: "Servus"}},
+			skipped: 1,
+		},
+		{
+			name:       "unapproved applied",
+			units:      []xliff.Unit{{ID: "TXT_HELLO", Source: "Hello", Target: "Servus"}},
+			unapproved: true,
+			want:       map[string]string{"TXT_HELLO": "Servus"},
+			updated:    1,
+		},
+		{
+			name:      "source changed",
+			units:     []xliff.Unit{{ID: "TXT_HELLO", Source: "Hi", Target: "Servus", Approved: true}},
+			conflicts: []string{"TXT_HELLO"},
+		},
+		{
+			name:    "source changed with force",
+			units:   []xliff.Unit{{ID: "TXT_HELLO", Source: "Hi", Target: "Servus", Approved: true}},
+			force:   true,
+			want:    map[string]string{"TXT_HELLO": "Servus"},
+			updated: 1,
+		},
+		{
+			name:      "unknown label",
+			units:     []xliff.Unit{{ID: "TXT_GONE", Target: "Weg"}},
+			conflicts: []string{"TXT_GONE"},
+		},
+		{
+			name:      "hash mismatch",
+			units:     []xliff.Unit{{ID: "TXT_HELLO", Hash: hashdict.Hash("TXT_CAR"), Source: "Hello", Target: "Servus", Approved: true}},
+			conflicts: []string{"TXT_HELLO"},
+		},
+		{
+			name:  "notes replaced",
+			units: []xliff.Unit{{ID: "TXT_CAR", Source: "Car", Notes: []string{"Keep it shorter"}}},
+			notes: map[string][]string{"TXT_CAR": {"Keep it shorter"}},
+		},
+		{
+			name:  "notes kept",
+			units: []xliff.Unit{{ID: "TXT_CAR", Source: "Car", Target: "Wagen \u00FC", Approved: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testProject()
+			p.Languages["German"].Notes = map[string][]string{"TXT_CAR": {"Keep it short"}}
+			want := map[string]string{"TXT_HELLO": "Hallo", "TXT_CAR": "Wagen \u00FC"}
+			for l, s := range tt.want {
+				want[l] = s
+			}
+			notes := tt.notes
+			if notes == nil {
+				notes = map[string][]string{"TXT_CAR": {"Keep it short"}}
+			}
+
+			res, err := p.ImportXliff(&xliff.Document{Units: tt.units}, "English", "German", tt.unapproved, tt.force)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.Updated != tt.updated || res.Unapproved != tt.skipped || res.Notes != len(tt.notes) {
+				t.Errorf("Unexpected result: %+v", res)
+			}
+			conflicts := make([]string, 0)
+			for _, c := range res.Conflicts {
+				conflicts = append(conflicts, c.Label)
+			}
+			if tt.conflicts == nil {
+				tt.conflicts = []string{}
+			}
+			if !reflect.DeepEqual(conflicts, tt.conflicts) {
+				t.Errorf("Unexpected conflicts: %+v", res.Conflicts)
+			}
+			if got := p.Languages["German"].Entries; !reflect.DeepEqual(got, want) {
+				t.Errorf("Unexpected German strings: %v", got)
+			}
+			if got := p.Languages["German"].Notes; !reflect.DeepEqual(got, notes) {
+				t.Errorf("Unexpected German notes: %v", got)
+			}
+		})
+	}
+}
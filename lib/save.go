@@ -5,13 +5,27 @@
 package lib
 
 import (
+	"bytes"
 	"encoding/binary"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/chunk"
 	"github.com/WorldUnitedNFS/worldlangedit/lib/xor"
+	"io"
 	"sort"
 	"strings"
 )
 
 func SaveFile(file *LangFile, lFile *LangFile, doXor bool) []byte {
+	var buf bytes.Buffer
+	err := Encode(&buf, file, lFile, doXor)
+	if err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+// Encode writes file to w, XOR-encoding it on the fly if doXor is set. lFile
+// is the labels file used to order the string table.
+func Encode(w io.Writer, file *LangFile, lFile *LangFile, doXor bool) error {
 	// char map debug
 	cm := file.CharMap
 
@@ -26,11 +40,8 @@ func SaveFile(file *LangFile, lFile *LangFile, doXor bool) []byte {
 	hEntries := make([]*hEntry, len(file.Entries))
 	for i, e := range file.Entries {
 		var label string
-		for _, le := range lFile.Entries {
-			if le.Hash == e.Hash {
-				label = le.String
-				break
-			}
+		if le := lFile.Get(e.Hash); le != nil {
+			label = le.String
 		}
 		//fmt.Printf("encoded %s in %d bytes\n", e.String, len(b))
 		hEntries[i] = &hEntry{
@@ -76,14 +87,15 @@ func SaveFile(file *LangFile, lFile *LangFile, doXor bool) []byte {
 		return false
 	})
 
-	for i, e := range file.Entries {
-		b := cm.EncodeString(e.String)
+	for i := range file.Entries {
+		e := &file.Entries[i]
+		b, err := encodeEntry(cm, e, lFile)
+		if err != nil {
+			return err
+		}
 		var label string
-		for _, le := range lFile.Entries {
-			if le.Hash == e.Hash {
-				label = le.String
-				break
-			}
+		if le := lFile.Get(e.Hash); le != nil {
+			label = le.String
 		}
 		//fmt.Printf("encoded %s in %d bytes\n", e.String, len(b))
 		hEntries[i] = &hEntry{
@@ -99,21 +111,17 @@ func SaveFile(file *LangFile, lFile *LangFile, doXor bool) []byte {
 	}
 	langLen := 36 + len(hEntries)*8 + stringsLen
 	langLen += 4 - (langLen % 4)
-	paddingLen := 16 - (langLen % 16)
-	if paddingLen-8 < 0 {
-		paddingLen += 16
-	}
-	totalLen := langLen + paddingLen + /*endData*/ 8 + 4 + (0xC00 * 2)
 
-	data := make([]byte, totalLen)
-	binary.LittleEndian.PutUint32(data[0:4], 0x39000)
-	binary.LittleEndian.PutUint32(data[4:8], uint32(langLen-8))
-	binary.LittleEndian.PutUint32(data[8:12], uint32(len(hEntries)))
-	binary.LittleEndian.PutUint32(data[12:16], 0x1C)
-	binary.LittleEndian.PutUint32(data[16:20], uint32(len(hEntries)*8+28))
-	copy(data[20:36], []byte{'G', 'l', 'o', 'b', 'a', 'l'})
+	// string chunk payload, offsets are relative to the start of the payload
+	data := make([]byte, langLen-8)
+	binary.LittleEndian.PutUint32(data[0:4], uint32(len(hEntries)))
+	binary.LittleEndian.PutUint32(data[4:8], stringHeaderSize)
+	binary.LittleEndian.PutUint32(data[8:12], uint32(len(hEntries)*8+stringHeaderSize))
+	if err := putName(data, file.Name); err != nil {
+		return err
+	}
 
-	offset := 36 + len(hEntries)*8
+	offset := stringHeaderSize + len(hEntries)*8
 	inOffset := 0
 	for _, e := range hEntries {
 		e.Offset = uint32(inOffset)
@@ -126,25 +134,87 @@ func SaveFile(file *LangFile, lFile *LangFile, doXor bool) []byte {
 	sort.SliceStable(hEntries, func(i, j int) bool {
 		return hEntries[i].Hash < hEntries[j].Hash
 	})
-	offset = 36
+	offset = stringHeaderSize
 	for _, e := range hEntries {
 		binary.LittleEndian.PutUint32(data[offset:offset+4], e.Hash)
 		binary.LittleEndian.PutUint32(data[offset+4:offset+8], e.Offset)
 		offset += 8
 	}
 
-	binary.LittleEndian.PutUint32(data[langLen+4:langLen+8], uint32(paddingLen-8))
-	//copy(data[langLen+paddingLen:], )
-	binary.LittleEndian.PutUint32(data[langLen+paddingLen:], 0x39001)
-	binary.LittleEndian.PutUint32(data[langLen+paddingLen+4:], 0x1804)
-	binary.LittleEndian.PutUint32(data[langLen+paddingLen+8:], uint32(cm.NumEntries))
-	for i := 0; i < 0xC00; i++ {
-		binary.LittleEndian.PutUint16(data[langLen+paddingLen+12+2*i:], cm.EntryTable[i])
+	if doXor {
+		w = xor.NewWriter(w)
 	}
 
-	if doXor {
-		return xor.Encode(data)
+	return writeChunks(w, saveChunks(file.Chunks, data, cm.ChunkData()))
+}
+
+// putName writes the name field of a string chunk payload, falling back to
+// DefaultName for unnamed packs.
+func putName(data []byte, name string) error {
+	if name == "" {
+		name = DefaultName
+	}
+	if len(name) >= nameSize {
+		return ErrNameTooLong
 	}
+	field := data[12 : 12+nameSize]
+	for i := range field {
+		field[i] = 0
+	}
+	copy(field, name)
+	return nil
+}
 
-	return data
+// saveChunks returns the chunks of the file being saved: the original chunk
+// order with the payloads of the first string and charmap chunks replaced and
+// the padding dropped. Later string and charmap chunks are kept as they are.
+func saveChunks(orig []chunk.Chunk, strData []byte, cmData []byte) []chunk.Chunk {
+	if len(orig) == 0 {
+		orig = []chunk.Chunk{{ID: StringChunkID}, {ID: CharMapChunkID}}
+	}
+
+	chunks := make([]chunk.Chunk, 0, len(orig))
+	var hasStrings, hasCharMap bool
+	for _, c := range orig {
+		switch c.ID {
+		case chunk.PaddingID:
+			continue
+		case StringChunkID:
+			if !hasStrings {
+				hasStrings = true
+				c.Data = strData
+			}
+		case CharMapChunkID:
+			if !hasCharMap {
+				hasCharMap = true
+				c.Data = cmData
+			}
+		}
+		chunks = append(chunks, c)
+	}
+	if !hasStrings {
+		chunks = append([]chunk.Chunk{{ID: StringChunkID, Data: strData}}, chunks...)
+	}
+	if !hasCharMap {
+		chunks = append(chunks, chunk.Chunk{ID: CharMapChunkID, Data: cmData})
+	}
+
+	return chunks
+}
+
+// writeChunks writes the chunks with a 16-byte aligning padding chunk in
+// between each of them.
+func writeChunks(w io.Writer, chunks []chunk.Chunk) error {
+	cw := chunk.NewWriter(w)
+	for i, c := range chunks {
+		if i > 0 {
+			if err := cw.Pad(16); err != nil {
+				return err
+			}
+		}
+		if err := cw.WriteChunk(c); err != nil {
+			return err
+		}
+	}
+	return nil
 }
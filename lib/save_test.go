@@ -0,0 +1,162 @@
+package lib_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/WorldUnitedNFS/worldlangedit/lib"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/charmap"
+)
+
+func TestRoundTripTestdata(t *testing.T) {
+	matches, err := filepath.Glob("../testdata/*.bin")
+
+	if err != nil {
+		t.Fatalf("Error occurred while listing test data: %v", err)
+	}
+
+	if len(matches) == 0 {
+		t.Skip("No language files in testdata")
+	}
+
+	for _, fp := range matches {
+		data, err := ioutil.ReadFile(fp)
+		if err != nil {
+			t.Errorf("Error occurred while reading file: %v", err)
+			continue
+		}
+
+		lf, err := lib.Parse(data)
+		if err != nil {
+			t.Errorf("Failed to parse %s: %v", fp, err)
+			continue
+		}
+
+		saved := lib.SaveFilePreserving(lf, lib.IsFileEncoded(data))
+		if !bytes.Equal(saved, data) {
+			t.Errorf("Saving %s without edits changed the file", fp)
+		}
+	}
+}
+
+func TestSavePreserving(t *testing.T) {
+	data := testPack()
+
+	lf, err := lib.Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse file: %v", err)
+	}
+
+	if !bytes.Equal(lib.SaveFilePreserving(lf, false), data) {
+		t.Fatalf("Saving without edits changed the file")
+	}
+
+	hello := lf.FindEntryByName("TXT_HELLO")
+	world := lf.FindEntryByName("TXT_WORLD")
+	for i := range lf.Entries {
+		if lf.Entries[i].Hash == hello.Hash {
+			lf.Entries[i].String = "Hello again"
+		}
+	}
+
+	edited, err := lib.Parse(lib.SaveFilePreserving(lf, true))
+	if err != nil {
+		t.Fatalf("Failed to parse saved file: %v", err)
+	}
+
+	if e := edited.FindEntryByName("TXT_HELLO"); e.String != "Hello again" {
+		t.Errorf("Expected 'Hello again', got %s", e.String)
+	}
+	if e := edited.FindEntryByName("TXT_WORLD"); e.Offset != world.Offset {
+		t.Errorf("Unchanged string moved from %d to %d", world.Offset, e.Offset)
+	}
+
+	lf.Set(lib.BinHash("TXT_NEW"), "New")
+	added, err := lib.Parse(lib.SaveFilePreserving(lf, false))
+	if err != nil {
+		t.Fatalf("Failed to parse saved file: %v", err)
+	}
+
+	want := []uint32{lib.BinHash("TXT_NEW"), hello.Hash, world.Hash}
+	if len(added.Entries) != len(want) {
+		t.Fatalf("Expected %d entries, got %d", len(want), len(added.Entries))
+	}
+	for i, h := range want {
+		if added.Entries[i].Hash != h {
+			t.Errorf("Entry %d has hash %08x, want %08x", i, added.Entries[i].Hash, h)
+		}
+	}
+}
+
+// reverseTable reverses the hash table of a plain pack saved by SaveFile, so
+// the table is no longer sorted by hash.
+func reverseTable(data []byte) {
+	payload := data[8:]
+	count := int(binary.LittleEndian.Uint32(payload[0:4]))
+	table := payload[binary.LittleEndian.Uint32(payload[4:8]):]
+	for i, j := 0, count-1; i < j; i, j = i+1, j-1 {
+		var tmp [8]byte
+		copy(tmp[:], table[i*8:i*8+8])
+		copy(table[i*8:i*8+8], table[j*8:j*8+8])
+		copy(table[j*8:j*8+8], tmp[:])
+	}
+}
+
+func TestSavePreservingUnsortedTable(t *testing.T) {
+	data := testPack()
+	reverseTable(data)
+
+	lf, err := lib.Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse file: %v", err)
+	}
+	if lf.Entries[0].Hash < lf.Entries[1].Hash {
+		t.Fatalf("Table is still sorted by hash")
+	}
+
+	if !bytes.Equal(lib.SaveFilePreserving(lf, false), data) {
+		t.Fatalf("Saving a table not sorted by hash without edits changed the file")
+	}
+
+	lf.Entries[1].String = "Earth"
+	lf.Set(lib.BinHash("TXT_NEW"), "New")
+	edited, err := lib.Parse(lib.SaveFilePreserving(lf, false))
+	if err != nil {
+		t.Fatalf("Failed to parse saved file: %v", err)
+	}
+
+	want := []uint32{lf.Entries[0].Hash, lf.Entries[1].Hash, lib.BinHash("TXT_NEW")}
+	if len(edited.Entries) != len(want) {
+		t.Fatalf("Expected %d entries, got %d", len(want), len(edited.Entries))
+	}
+	for i, h := range want {
+		if edited.Entries[i].Hash != h {
+			t.Errorf("Entry %d has hash %08x, want %08x", i, edited.Entries[i].Hash, h)
+		}
+	}
+	if edited.Entries[0].Offset != lf.Entries[0].Offset || edited.Entries[1].String != "Earth" {
+		t.Errorf("Unexpected entries after editing: %v", edited.Entries)
+	}
+}
+
+func TestSavePreservingEncodeError(t *testing.T) {
+	lf, err := lib.Parse(testPack())
+	if err != nil {
+		t.Fatalf("Failed to parse file: %v", err)
+	}
+
+	lf.Entries[0].String = "\u20AC"
+	var buf bytes.Buffer
+	err = lib.EncodePreserving(&buf, lf, false)
+	var ee *charmap.EncodeError
+	if !errors.As(err, &ee) {
+		t.Fatalf("Expected an error for a character missing from the charmap, got %v", err)
+	}
+	if ee.Label != lib.HexKey(lf.Entries[0].Hash) {
+		t.Errorf("Expected the entry to be named %s, got %s", lib.HexKey(lf.Entries[0].Hash), ee.Label)
+	}
+}
@@ -4,11 +4,24 @@
 
 package lib
 
-import "github.com/WorldUnitedNFS/worldlangedit/lib/charmap"
+import (
+	"github.com/WorldUnitedNFS/worldlangedit/lib/charmap"
+	"github.com/WorldUnitedNFS/worldlangedit/lib/chunk"
+)
 
 type LangFile struct {
+	// Name is the pack name stored in the file header, such as "Global".
+	Name    string
 	Entries []LangFileEntry
 	CharMap *charmap.Charmap
+	// Chunks holds every chunk of the parsed file in order. Chunks other than
+	// the string, charmap and padding chunks are written back unchanged.
+	Chunks []chunk.Chunk
+
+	// index maps a hash to the position of its first entry in Entries. It is
+	// rebuilt lazily when Entries is modified directly.
+	index   map[uint32]int
+	indexed int
 }
 
 type LangFileEntry struct {
@@ -18,16 +31,94 @@ type LangFileEntry struct {
 	OriginalBytes []byte
 }
 
-func (lf *LangFile) FindEntryByHash(hash uint32) *LangFileEntry {
-	for _, e := range lf.Entries {
-		if e.Hash == hash {
-			return &e
+// Reindex rebuilds the hash index. It only needs to be called after changing
+// the Hash of an entry in Entries directly.
+func (lf *LangFile) Reindex() {
+	lf.index = make(map[uint32]int, len(lf.Entries))
+	for i, e := range lf.Entries {
+		if _, exists := lf.index[e.Hash]; !exists {
+			lf.index[e.Hash] = i
 		}
 	}
+	lf.indexed = len(lf.Entries)
+}
 
+func (lf *LangFile) lookup(hash uint32) (int, bool) {
+	if lf.index == nil || lf.indexed != len(lf.Entries) {
+		lf.Reindex()
+	}
+	i, ok := lf.index[hash]
+	if ok && i < len(lf.Entries) && lf.Entries[i].Hash == hash {
+		return i, true
+	}
+	if ok {
+		// Entries was reordered behind our back
+		lf.Reindex()
+		i, ok = lf.index[hash]
+	}
+	return i, ok
+}
+
+// Get returns a pointer to the entry with the given hash, or nil. The pointer
+// is only valid until Entries is next modified.
+func (lf *LangFile) Get(hash uint32) *LangFileEntry {
+	if i, ok := lf.lookup(hash); ok {
+		return &lf.Entries[i]
+	}
 	return nil
 }
 
+func (lf *LangFile) Has(hash uint32) bool {
+	_, ok := lf.lookup(hash)
+	return ok
+}
+
+// Set changes the string of the entry with the given hash, adding a new entry
+// if there is none.
+func (lf *LangFile) Set(hash uint32, s string) *LangFileEntry {
+	if i, ok := lf.lookup(hash); ok {
+		lf.Entries[i].String = s
+		return &lf.Entries[i]
+	}
+	lf.Entries = append(lf.Entries, LangFileEntry{
+		Hash:   hash,
+		String: s,
+	})
+	i := len(lf.Entries) - 1
+	lf.index[hash] = i
+	lf.indexed = len(lf.Entries)
+	return &lf.Entries[i]
+}
+
+// Delete removes the entry with the given hash, keeping the order of the
+// remaining entries. It reports whether an entry was removed.
+func (lf *LangFile) Delete(hash uint32) bool {
+	i, ok := lf.lookup(hash)
+	if !ok {
+		return false
+	}
+	lf.Entries = append(lf.Entries[:i], lf.Entries[i+1:]...)
+	lf.Reindex()
+	return true
+}
+
+func (lf *LangFile) Len() int {
+	return len(lf.Entries)
+}
+
+// Range calls fn for every entry in file order until fn returns false.
+func (lf *LangFile) Range(fn func(e *LangFileEntry) bool) {
+	for i := range lf.Entries {
+		if !fn(&lf.Entries[i]) {
+			return
+		}
+	}
+}
+
+func (lf *LangFile) FindEntryByHash(hash uint32) *LangFileEntry {
+	return lf.Get(hash)
+}
+
 func (lf *LangFile) FindEntryByName(name string) *LangFileEntry {
 	return lf.FindEntryByHash(BinHash(name))
 }
@@ -0,0 +1,39 @@
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/WorldUnitedNFS/worldlangedit/lib"
+)
+
+func TestLangFileIndex(t *testing.T) {
+	lf := &lib.LangFile{}
+	lf.Set(1, "one")
+	lf.Set(2, "two")
+	lf.Entries = append(lf.Entries, lib.LangFileEntry{Hash: 3, String: "three"})
+
+	if !lf.Has(3) {
+		t.Errorf("Entry appended to Entries directly is not indexed")
+	}
+
+	lf.Get(2).String = "deux"
+	if lf.Entries[1].String != "deux" {
+		t.Errorf("Edit through Get was lost. Expected 'deux', got %s", lf.Entries[1].String)
+	}
+
+	if !lf.Delete(1) || lf.Has(1) {
+		t.Errorf("Failed to delete entry")
+	}
+	if lf.Len() != 2 || lf.Get(3).String != "three" {
+		t.Errorf("Index is inconsistent after delete")
+	}
+
+	hashes := make([]uint32, 0)
+	lf.Range(func(e *lib.LangFileEntry) bool {
+		hashes = append(hashes, e.Hash)
+		return true
+	})
+	if len(hashes) != 2 || hashes[0] != 2 || hashes[1] != 3 {
+		t.Errorf("Range did not follow file order: %v", hashes)
+	}
+}
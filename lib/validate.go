@@ -0,0 +1,42 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package lib
+
+import "github.com/WorldUnitedNFS/worldlangedit/lib/charmap"
+
+// ValidateStrings returns a *charmap.EncodeError for every entry of file that
+// can't be encoded with its charmap. lFile is used to label the errors and may
+// be nil.
+func ValidateStrings(file *LangFile, lFile *LangFile) []error {
+	errs := make([]error, 0)
+
+	for i := range file.Entries {
+		if _, err := encodeEntry(file.CharMap, &file.Entries[i], lFile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// encodeEntry encodes the string of e, naming the entry in errors.
+func encodeEntry(cm *charmap.Charmap, e *LangFileEntry, lFile *LangFile) ([]byte, error) {
+	b, err := cm.Encode(e.String)
+	if ee, ok := err.(*charmap.EncodeError); ok {
+		ee.Label = entryLabel(lFile, e.Hash)
+	}
+	return b, err
+}
+
+// entryLabel returns the label of hash in lFile, or the hash itself if it has
+// none.
+func entryLabel(lFile *LangFile, hash uint32) string {
+	if lFile != nil {
+		if le := lFile.Get(hash); le != nil {
+			return le.String
+		}
+	}
+	return HexKey(hash)
+}
@@ -0,0 +1,362 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package xliff reads and writes XLIFF 1.2 and 2.0 documents.
+package xliff
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+)
+
+const (
+	Version12 = "1.2"
+	Version20 = "2.0"
+
+	namespace12 = "urn:oasis:names:tc:xliff:document:1.2"
+	namespace20 = "urn:oasis:names:tc:xliff:document:2.0"
+	// Namespace of the hash attribute on units.
+	Namespace = "https://github.com/WorldUnitedNFS/worldlangedit"
+)
+
+var ErrVersion = errors.New("unsupported XLIFF version")
+
+// Unit is a translatable string. ID is the label of the string.
+type Unit struct {
+	ID       string
+	Hash     uint32
+	Source   string
+	Target   string
+	Notes    []string
+	Approved bool
+}
+
+// Property is an attribute in Namespace on the file element.
+type Property struct {
+	Name  string
+	Value string
+}
+
+type Document struct {
+	Version        string
+	SourceLanguage string
+	TargetLanguage string
+	// Original names the pack the units come from.
+	Original   string
+	Properties []Property
+	Units      []Unit
+}
+
+type note struct {
+	Text string `xml:",chardata"`
+}
+
+type target12 struct {
+	Text  string `xml:",chardata"`
+	State string `xml:"state,attr,omitempty"`
+}
+
+type unit12 struct {
+	ID       string    `xml:"id,attr"`
+	Hash     string    `xml:"https://github.com/WorldUnitedNFS/worldlangedit hash,attr,omitempty"`
+	Approved string    `xml:"approved,attr,omitempty"`
+	Source   string    `xml:"source"`
+	Target   *target12 `xml:"target"`
+	Notes    []note    `xml:"note"`
+}
+
+type file12 struct {
+	Original       string     `xml:"original,attr"`
+	SourceLanguage string     `xml:"source-language,attr"`
+	TargetLanguage string     `xml:"target-language,attr,omitempty"`
+	Datatype       string     `xml:"datatype,attr"`
+	Attrs          []xml.Attr `xml:",any,attr"`
+	Units          []unit12   `xml:"body>trans-unit"`
+}
+
+type xliff12 struct {
+	XMLName xml.Name `xml:"urn:oasis:names:tc:xliff:document:1.2 xliff"`
+	Version string   `xml:"version,attr"`
+	Files   []file12 `xml:"file"`
+}
+
+type segment20 struct {
+	State  string  `xml:"state,attr,omitempty"`
+	Source string  `xml:"source"`
+	Target *string `xml:"target"`
+}
+
+// XLIFF 2.0 doesn't allow an empty notes element
+type notes20 struct {
+	Notes []note `xml:"note"`
+}
+
+type unit20 struct {
+	ID       string      `xml:"id,attr"`
+	Hash     string      `xml:"https://github.com/WorldUnitedNFS/worldlangedit hash,attr,omitempty"`
+	Notes    *notes20    `xml:"notes"`
+	Segments []segment20 `xml:"segment"`
+}
+
+type file20 struct {
+	ID    string     `xml:"id,attr"`
+	Attrs []xml.Attr `xml:",any,attr"`
+	Units []unit20   `xml:"unit"`
+}
+
+type xliff20 struct {
+	XMLName xml.Name `xml:"urn:oasis:names:tc:xliff:document:2.0 xliff"`
+	Version string   `xml:"version,attr"`
+	SrcLang string   `xml:"srcLang,attr"`
+	TrgLang string   `xml:"trgLang,attr,omitempty"`
+	Files   []file20 `xml:"file"`
+}
+
+func formatHash(hash uint32) string {
+	if hash == 0 {
+		return ""
+	}
+	return fmt.Sprintf("0x%08X", hash)
+}
+
+func parseHash(s string) (uint32, error) {
+	if s == "" {
+		return 0, nil
+	}
+	hash, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("bad hash %q", s)
+	}
+	return uint32(hash), nil
+}
+
+func notes(n []note) []string {
+	out := make([]string, len(n))
+	for i, t := range n {
+		out[i] = t.Text
+	}
+	return out
+}
+
+func toNotes(s []string) []note {
+	out := make([]note, len(s))
+	for i, t := range s {
+		out[i] = note{Text: t}
+	}
+	return out
+}
+
+func (d *Document) attrs() []xml.Attr {
+	attrs := make([]xml.Attr, len(d.Properties))
+	for i, p := range d.Properties {
+		attrs[i] = xml.Attr{Name: xml.Name{Space: Namespace, Local: p.Name}, Value: p.Value}
+	}
+	return attrs
+}
+
+func properties(attrs []xml.Attr) []Property {
+	var props []Property
+	for _, a := range attrs {
+		if a.Name.Space == Namespace {
+			props = append(props, Property{Name: a.Name.Local, Value: a.Value})
+		}
+	}
+	return props
+}
+
+func (d *Document) marshal12() interface{} {
+	f := file12{
+		Original:       d.Original,
+		SourceLanguage: d.SourceLanguage,
+		TargetLanguage: d.TargetLanguage,
+		Datatype:       "plaintext",
+		Attrs:          d.attrs(),
+		Units:          make([]unit12, len(d.Units)),
+	}
+	for i, u := range d.Units {
+		x := unit12{
+			ID:     u.ID,
+			Hash:   formatHash(u.Hash),
+			Source: u.Source,
+			Notes:  toNotes(u.Notes),
+		}
+		if u.Target != "" {
+			x.Target = &target12{Text: u.Target, State: "translated"}
+		}
+		if u.Approved {
+			x.Approved = "yes"
+			if x.Target != nil {
+				x.Target.State = "final"
+			}
+		}
+		f.Units[i] = x
+	}
+	return &xliff12{Version: Version12, Files: []file12{f}}
+}
+
+func (d *Document) marshal20() interface{} {
+	f := file20{
+		ID:    d.Original,
+		Attrs: d.attrs(),
+		Units: make([]unit20, len(d.Units)),
+	}
+	for i, u := range d.Units {
+		s := segment20{Source: u.Source}
+		if u.Target != "" {
+			target := u.Target
+			s.Target = &target
+			s.State = "translated"
+		}
+		if u.Approved {
+			s.State = "final"
+		}
+		f.Units[i] = unit20{
+			ID:       u.ID,
+			Hash:     formatHash(u.Hash),
+			Segments: []segment20{s},
+		}
+		if len(u.Notes) > 0 {
+			f.Units[i].Notes = &notes20{Notes: toNotes(u.Notes)}
+		}
+	}
+	return &xliff20{Version: Version20, SrcLang: d.SourceLanguage, TrgLang: d.TargetLanguage, Files: []file20{f}}
+}
+
+// Write writes d in the XLIFF version it names.
+func (d *Document) Write(w io.Writer) error {
+	var v interface{}
+	switch d.Version {
+	case Version12:
+		v = d.marshal12()
+	case Version20:
+		v = d.marshal20()
+	default:
+		return ErrVersion
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func read12(data []byte) (*Document, error) {
+	x := &xliff12{}
+	if err := xml.Unmarshal(data, x); err != nil {
+		return nil, err
+	}
+
+	d := &Document{Version: Version12, Units: make([]Unit, 0)}
+	for i, f := range x.Files {
+		if i == 0 {
+			d.Original = f.Original
+			d.SourceLanguage = f.SourceLanguage
+			d.TargetLanguage = f.TargetLanguage
+			d.Properties = properties(f.Attrs)
+		}
+		for _, u := range f.Units {
+			hash, err := parseHash(u.Hash)
+			if err != nil {
+				return nil, fmt.Errorf("trans-unit %s: %v", u.ID, err)
+			}
+			unit := Unit{
+				ID:       u.ID,
+				Hash:     hash,
+				Source:   u.Source,
+				Notes:    notes(u.Notes),
+				Approved: u.Approved == "yes",
+			}
+			if u.Target != nil {
+				unit.Target = u.Target.Text
+				if u.Target.State == "final" || u.Target.State == "signed-off" {
+					unit.Approved = true
+				}
+			}
+			d.Units = append(d.Units, unit)
+		}
+	}
+	return d, nil
+}
+
+func read20(data []byte) (*Document, error) {
+	x := &xliff20{}
+	if err := xml.Unmarshal(data, x); err != nil {
+		return nil, err
+	}
+
+	d := &Document{
+		Version:        Version20,
+		SourceLanguage: x.SrcLang,
+		TargetLanguage: x.TrgLang,
+		Units:          make([]Unit, 0),
+	}
+	for i, f := range x.Files {
+		if i == 0 {
+			d.Original = f.ID
+			d.Properties = properties(f.Attrs)
+		}
+		for _, u := range f.Units {
+			hash, err := parseHash(u.Hash)
+			if err != nil {
+				return nil, fmt.Errorf("unit %s: %v", u.ID, err)
+			}
+			unit := Unit{
+				ID:       u.ID,
+				Hash:     hash,
+				Notes:    make([]string, 0),
+				Approved: len(u.Segments) > 0,
+			}
+			if u.Notes != nil {
+				unit.Notes = notes(u.Notes.Notes)
+			}
+			// a unit is approved when all of its segments are
+			for _, s := range u.Segments {
+				unit.Source += s.Source
+				if s.Target != nil {
+					unit.Target += *s.Target
+				}
+				if s.State != "final" {
+					unit.Approved = false
+				}
+			}
+			d.Units = append(d.Units, unit)
+		}
+	}
+	return d, nil
+}
+
+// Read reads an XLIFF 1.2 or 2.0 document.
+func Read(r io.Reader) (*Document, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var root struct {
+		XMLName xml.Name
+		Version string `xml:"version,attr"`
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case root.XMLName.Space == namespace12 && root.Version == Version12:
+		return read12(data)
+	case root.XMLName.Space == namespace20 && root.Version == Version20:
+		return read20(data)
+	default:
+		return nil, ErrVersion
+	}
+}
@@ -0,0 +1,95 @@
+package xliff_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/WorldUnitedNFS/worldlangedit/lib/xliff"
+)
+
+func testDocument(version string) *xliff.Document {
+	return &xliff.Document{
+		Version:        version,
+		SourceLanguage: "en",
+		TargetLanguage: "de",
+		Original:       "Global",
+		Properties:     []xliff.Property{{Name: "Name", Value: "Career"}},
+		Units: []xliff.Unit{
+			{ID: "TXT_HELLO", Hash: 0x905FBB72, Source: "Hello", Target: "Hallo", Approved: true, Notes: []string{"greeting"}},
+			{ID: "TXT_WORLD", Hash: 0x9174C046, Source: "World <&>\n", Target: "Welt", Notes: []string{}},
+			{ID: "GM_CAR_DESC", Source: "A fast car", Notes: []string{}},
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, version := range []string{xliff.Version12, xliff.Version20} {
+		doc := testDocument(version)
+
+		var buf bytes.Buffer
+		if err := doc.Write(&buf); err != nil {
+			t.Fatalf("%s: failed to write: %v", version, err)
+		}
+		read, err := xliff.Read(&buf)
+		if err != nil {
+			t.Fatalf("%s: failed to read: %v", version, err)
+		}
+
+		if !reflect.DeepEqual(doc, read) {
+			t.Errorf("%s: document differs after round trip:\n%+v\n%+v", version, doc, read)
+		}
+	}
+}
+
+func TestWriteNotes(t *testing.T) {
+	tests := []struct {
+		version string
+		notes   string
+	}{
+		{xliff.Version12, "<note>greeting</note>"},
+		{xliff.Version20, "<notes><note>greeting</note></notes>"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := testDocument(tt.version).Write(&buf); err != nil {
+			t.Fatalf("%s: failed to write: %v", tt.version, err)
+		}
+
+		out := strings.Join(strings.Fields(buf.String()), "")
+		if strings.Count(out, "<note>") != 1 || !strings.Contains(out, tt.notes) {
+			t.Errorf("%s: expected a single note written as %s, got:\n%s", tt.version, tt.notes, buf.String())
+		}
+		if strings.Contains(out, "<notes></notes>") || strings.Contains(out, "<notes/>") {
+			t.Errorf("%s: empty notes element written", tt.version)
+		}
+	}
+}
+
+func TestRead12(t *testing.T) {
+	doc, err := xliff.Read(strings.NewReader(`<?xml version="1.0"?>
+<xliff version="1.2" xmlns="urn:oasis:names:tc:xliff:document:1.2">
+ <file original="Global" source-language="en" target-language="de" datatype="plaintext">
+  <body>
+   <trans-unit id="A"><source>a</source><target state="signed-off">x</target></trans-unit>
+   <trans-unit id="B"><source>b</source><target state="needs-review-translation">y</target></trans-unit>
+  </body>
+ </file>
+</xliff>`))
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+
+	if len(doc.Units) != 2 || !doc.Units[0].Approved || doc.Units[1].Approved || doc.Units[1].Target != "y" {
+		t.Errorf("Unexpected units: %+v", doc.Units)
+	}
+}
+
+func TestReadVersion(t *testing.T) {
+	_, err := xliff.Read(strings.NewReader(`<xliff version="1.1" xmlns="urn:oasis:names:tc:xliff:document:1.1"/>`))
+	if err != xliff.ErrVersion {
+		t.Errorf("Expected %v, got %v", xliff.ErrVersion, err)
+	}
+}
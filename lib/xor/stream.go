@@ -0,0 +1,59 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package xor
+
+import "io"
+
+const seed = 0x6B
+
+type reader struct {
+	r    io.Reader
+	prev byte
+}
+
+// NewReader returns a reader that decodes the XOR-encoded stream r on the fly.
+func NewReader(r io.Reader) io.Reader {
+	return &reader{r: r, prev: seed}
+}
+
+func (x *reader) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	for i := 0; i < n; i++ {
+		c := p[i]
+		p[i] = c ^ x.prev
+		x.prev = c
+	}
+	return n, err
+}
+
+type writer struct {
+	w    io.Writer
+	prev byte
+	buf  []byte
+}
+
+// NewWriter returns a writer that XOR-encodes everything written to it before
+// passing it on to w.
+func NewWriter(w io.Writer) io.Writer {
+	return &writer{w: w, prev: seed, buf: make([]byte, 4096)}
+}
+
+func (x *writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := copy(x.buf, p)
+		for i := 0; i < n; i++ {
+			x.buf[i] ^= x.prev
+			x.prev = x.buf[i]
+		}
+		m, err := x.w.Write(x.buf[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
@@ -14,7 +14,7 @@ func Decode(b []byte) []byte {
 		out[i] = b[i] ^ b[i-1]
 		i--
 	}
-	out[0] = b[0] ^ 0x6B
+	out[0] = b[0] ^ seed
 	return out
 }
 
@@ -23,7 +23,7 @@ func Encode(b []byte) []byte {
 		return []byte{}
 	}
 	out := make([]byte, len(b))
-	out[0] = b[0] ^ 0x6B
+	out[0] = b[0] ^ seed
 	i := 1
 	for i < len(b) {
 		out[i] = b[i] ^ out[i-1]
@@ -0,0 +1,40 @@
+package xor_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/WorldUnitedNFS/worldlangedit/lib/xor"
+)
+
+func TestStreamMatchesBuffer(t *testing.T) {
+	plain := make([]byte, 10000)
+	for i := range plain {
+		plain[i] = byte(i * 7)
+	}
+
+	var enc bytes.Buffer
+	w := xor.NewWriter(&enc)
+	// uneven writes to cross the internal buffer boundary
+	for i := 0; i < len(plain); i += 3001 {
+		end := i + 3001
+		if end > len(plain) {
+			end = len(plain)
+		}
+		if _, err := w.Write(plain[i:end]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if !bytes.Equal(enc.Bytes(), xor.Encode(plain)) {
+		t.Errorf("NewWriter output differs from Encode")
+	}
+
+	dec, err := ioutil.ReadAll(xor.NewReader(bytes.NewReader(enc.Bytes())))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !bytes.Equal(dec, plain) {
+		t.Errorf("NewReader output differs from the original data")
+	}
+}